@@ -109,3 +109,50 @@ func CallStack() []string {
 
 	return stack
 }
+
+// Frame 是调用堆栈中的一帧
+type Frame struct {
+	// Function 是完整的函数名，如 github.com/tm-ad/g-base/log.(*Logger).Error
+	Function string
+	// File 是源文件的完整路径
+	File string
+	// Line 是源文件中的行号
+	Line int
+}
+
+// String 输出 "function file:line" 格式的帧信息
+func (f Frame) String() string {
+	return fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line)
+}
+
+// CallFrames 获取包含函数名的调用堆栈，skip 为跳过的调用者层数，0 表示 CallFrames 的调用者
+func CallFrames(skip int) []Frame {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(skip+2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	stack := make([]Frame, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, Frame{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+		})
+		if !more {
+			break
+		}
+	}
+
+	return stack
+}
@@ -97,3 +97,14 @@ func TestCallStack(t *testing.T) {
 		So(len(s), ShouldEqual, 16)
 	})
 }
+
+func TestCallFrames_first_frame_is_caller(t *testing.T) {
+	Convey("CallFrames的第一帧是调用者并包含函数名", t, func() {
+		frames := CallFrames(0)
+
+		So(len(frames), ShouldBeGreaterThan, 0)
+		So(frames[0].Function, ShouldStartWith, "github.com/tm-ad/g-base/exceptions_test.TestCallFrames_first_frame_is_caller")
+		So(frames[0].File, ShouldEndWith, "exception_test.go")
+		So(frames[0].Line, ShouldBeGreaterThan, 0)
+	})
+}
@@ -6,4 +6,5 @@ require (
 	github.com/json-iterator/go v1.1.7
 	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337
 	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a
+	gopkg.in/yaml.v2 v2.2.8
 )
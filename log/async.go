@@ -0,0 +1,188 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what happens to a log
+// when the queue of an asynchronous Logger is full.
+type OverflowPolicy uint8
+
+const (
+	// BlockOnFull blocks the caller until the queue has room, nothing is dropped.
+	BlockOnFull OverflowPolicy = iota
+	// DropNewest drops the log which can't be queued.
+	DropNewest
+	// DropBelowLevel drops the logs which are less important than
+	// the `AsyncOptions#DropLevel`, i.e info and debug when it's `WarnLevel`,
+	// the rest block like `BlockOnFull`.
+	DropBelowLevel
+)
+
+// DefaultAsyncQueueSize is the queue size of an asynchronous Logger
+// when the `AsyncOptions#QueueSize` is not positive.
+const DefaultAsyncQueueSize = 1024
+
+// AsyncOptions are the options of `Logger#SetAsync`.
+type AsyncOptions struct {
+	// QueueSize is the maximum number of the pending logs, defaults to `DefaultAsyncQueueSize`.
+	QueueSize int
+	// Overflow is the policy which is applied when the queue is full, defaults to `BlockOnFull`.
+	Overflow OverflowPolicy
+	// DropLevel is the least important level which is kept by `DropBelowLevel`.
+	DropLevel Level
+}
+
+// asyncItem is either a log to write or a flush request.
+type asyncItem struct {
+	log     *Log
+	flushed chan struct{}
+}
+
+// asyncQueue writes the logs on a background goroutine.
+type asyncQueue struct {
+	opts  AsyncOptions
+	queue chan asyncItem
+	done  chan struct{}
+}
+
+func newAsyncQueue(opts AsyncOptions) *asyncQueue {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultAsyncQueueSize
+	}
+
+	q := &asyncQueue{
+		opts:  opts,
+		queue: make(chan asyncItem, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *asyncQueue) run() {
+	for item := range q.queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		item.log.Logger.writeNow(item.log)
+	}
+	close(q.done)
+}
+
+// push queues a copy of the "log", it reports false if the log was dropped.
+func (q *asyncQueue) push(log *Log) bool {
+	entry := new(Log)
+	*entry = *log
+	item := asyncItem{log: entry}
+
+	switch q.opts.Overflow {
+	case DropNewest:
+		return q.tryPush(item)
+	case DropBelowLevel:
+		if log.Level > q.opts.DropLevel {
+			return q.tryPush(item)
+		}
+	}
+
+	q.queue <- item
+	return true
+}
+
+func (q *asyncQueue) tryPush(item asyncItem) bool {
+	select {
+	case q.queue <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// flush waits until the logs which were queued before the call are written.
+func (q *asyncQueue) flush() {
+	flushed := make(chan struct{})
+	q.queue <- asyncItem{flushed: flushed}
+	<-flushed
+}
+
+// close writes the pending logs and stops the background goroutine,
+// no log should be pushed after close.
+func (q *asyncQueue) close() {
+	close(q.queue)
+	<-q.done
+}
+
+// asyncDispatcher is embedded to the dispatcher and shared with its forks,
+// it's guarded by its own lock in order to not block the
+// background writer which reads the outputs.
+type asyncDispatcher struct {
+	// dropped is the first field to keep it 64-bit aligned.
+	dropped uint64
+	asyncMu sync.RWMutex
+	async   *asyncQueue
+}
+
+// enqueue reports whether the "log" was handed to the asynchronous queue,
+// when false the caller should write it synchronously.
+func (d *asyncDispatcher) enqueue(log *Log) bool {
+	d.asyncMu.RLock()
+	defer d.asyncMu.RUnlock()
+
+	if d.async == nil {
+		return false
+	}
+
+	if !d.async.push(log) {
+		atomic.AddUint64(&d.dropped, 1)
+	}
+	return true
+}
+
+func (d *asyncDispatcher) setAsync(q *asyncQueue) {
+	d.asyncMu.Lock()
+	old := d.async
+	d.async = q
+	d.asyncMu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+}
+
+func (d *asyncDispatcher) flushAsync() {
+	d.asyncMu.RLock()
+	defer d.asyncMu.RUnlock()
+
+	if d.async != nil {
+		d.async.flush()
+	}
+}
+
+// SetAsync makes "l", and every logger which shares its outputs,
+// write the logs on a background goroutine through a bounded queue,
+// the "opts" decide what happens when the queue is full.
+//
+// Calling it again replaces the queue after the pending logs are written.
+// Call `Flush` to wait for the queued logs and `Close` to stop the background goroutine,
+// the logs are written synchronously after `Close`.
+//
+// Returns itself.
+func (l *Logger) SetAsync(opts AsyncOptions) *Logger {
+	l.dispatcher.setAsync(newAsyncQueue(opts))
+	return l
+}
+
+// Close writes the pending logs of an asynchronous Logger,
+// stops its background goroutine and flushes the outputs, see `Flush`.
+func (l *Logger) Close() error {
+	l.dispatcher.setAsync(nil)
+	return l.dispatcher.syncOutputs()
+}
+
+// Dropped returns the number of logs that were dropped
+// because the asynchronous queue was full.
+func (l *Logger) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dispatcher.dropped)
+}
@@ -0,0 +1,72 @@
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+// gateWriter blocks every write until the gate is opened.
+type gateWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestLogger_SetAsync(t *testing.T) {
+	Convey("异步模式下 Flush 后所有日志都已写出", t, func() {
+		l, buf := newBufferLogger()
+		l.SetAsync(AsyncOptions{QueueSize: 2})
+		defer l.Close()
+
+		for i := 0; i < 10; i++ {
+			l.Info("hello")
+		}
+		l.Flush()
+
+		So(strings.Count(buf.String(), "[INFO] hello\n"), ShouldEqual, 10)
+		So(l.Dropped(), ShouldEqual, 0)
+	})
+
+	Convey("队列满时按策略丢弃日志并计数", t, func() {
+		w := &gateWriter{gate: make(chan struct{})}
+		l := New().SetTimeFormat("").SetOutput(w)
+		l.SetAsync(AsyncOptions{QueueSize: 1, Overflow: DropBelowLevel, DropLevel: WarnLevel})
+
+		// the first one is held by the writer, the second one fills the queue.
+		l.Info("first")
+		l.Info("second")
+		for i := 0; i < 5; i++ {
+			l.Info("dropped")
+		}
+		So(l.Dropped(), ShouldBeGreaterThanOrEqualTo, 4)
+
+		close(w.gate)
+		l.Error("kept")
+		So(l.Close(), ShouldBeNil)
+
+		So(w.String(), ShouldContainSubstring, "[ERRO] kept\n")
+
+		Convey("Close 之后同步写出", func() {
+			l.Info("sync")
+			So(w.String(), ShouldEndWith, "[INFO] sync\n")
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"io"
+	stdlog "log"
+	"os"
+	"strings"
+
+	"github.com/tm-ad/g-base/util/pio"
+)
+
+// Writer returns an `io.Writer` which prints each write as a log of the "level",
+// the trailing new line is removed from the message.
+// It can be passed to any library that accepts an `io.Writer` as its log output.
+//
+// Unlike the `Fatal` and `Panic` methods, the fatal and panic writes
+// neither exit nor panic, that is up to the writer of the log.
+func (l *Logger) Writer(level Level) io.Writer {
+	return pio.OutputFrom.Println(func(s string) {
+		l.record(level, trimLine(s), true, false, nil)
+	}, false)
+}
+
+// StdLogger returns a logger of the standard library which prints
+// through `Writer` with the "level", i.e for the `http.Server#ErrorLog`:
+//
+// srv := &http.Server{ErrorLog: logger.StdLogger(log.ErrorLevel)}
+func (l *Logger) StdLogger(level Level) *stdlog.Logger {
+	return stdlog.New(l.Writer(level), "", 0)
+}
+
+// RedirectStdLog redirects the output of the standard library's logger to the "logger",
+// the lines which start with a level marker, i.e "[WARN] message", "error: message" or "level=debug message",
+// are logged with that level and the rest, i.e "Error connecting to db", are logged with the "level" as they are.
+//
+// The flags and the prefix of the standard logger are cleared because
+// the "logger" adds its own time and prefix.
+// It returns a function which restores the flags, the prefix
+// and the default output, the `os.Stderr`, of the standard logger.
+func RedirectStdLog(logger *Logger, level Level) (restore func()) {
+	flags, prefix := stdlog.Flags(), stdlog.Prefix()
+
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(pio.OutputFrom.Println(func(s string) {
+		lineLevel, msg := parseLine(logger, trimLine(s), level)
+		logger.record(lineLevel, msg, true, false, nil)
+	}, false))
+
+	return func() {
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(os.Stderr)
+	}
+}
+
+func trimLine(s string) string {
+	return strings.TrimRight(s, "\r\n")
+}
+
+// parseLine returns the level of the marker which the "line" starts with and the rest of the line,
+// if the line doesn't start with a marker then it returns the "level" and the whole line.
+//
+// A marker is a level name of the "l", i.e "warn" or "WARNING", wrapped by brackets,
+// followed by a colon or after a "level=", i.e "[warn]", "WARN:" and "level=warn",
+// or a `LevelMetadata#RawText`, i.e "[WARN]". A line which just starts with a level name,
+// i.e "Error connecting to db", is logged as it is.
+func parseLine(l *Logger, line string, level Level) (Level, string) {
+	token := line
+	rest := ""
+	if idx := strings.IndexByte(line, ' '); idx != -1 {
+		token, rest = line[:idx], strings.TrimLeft(line[idx+1:], " ")
+	}
+
+	for parsed, meta := range Levels {
+		if parsed != DisableLevel && meta.RawText != "" && strings.EqualFold(meta.RawText, token) {
+			return parsed, rest
+		}
+	}
+
+	var name string
+	switch lower := strings.ToLower(token); {
+	case len(lower) > 2 && strings.HasPrefix(lower, "[") && strings.HasSuffix(lower, "]"):
+		name = lower[1 : len(lower)-1]
+	case len(lower) > 1 && strings.HasSuffix(lower, ":"):
+		name = lower[:len(lower)-1]
+	case strings.HasPrefix(lower, "level="):
+		name = strings.Trim(lower[len("level="):], `"`)
+	default:
+		return level, line
+	}
+
+	if parsed, err := l.ParseLevelStrict(name); err == nil && parsed != DisableLevel {
+		return parsed, rest
+	}
+	return level, line
+}
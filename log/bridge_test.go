@@ -0,0 +1,69 @@
+package log_test
+
+import (
+	stdlog "log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+func TestLogger_Writer(t *testing.T) {
+	Convey("Writer 将每次写入输出为指定级别的日志", t, func() {
+		l, buf := newBufferLogger()
+
+		w := l.Writer(WarnLevel)
+		w.Write([]byte("disk is almost full\n"))
+		So(buf.String(), ShouldEqual, "[WARN] disk is almost full\n")
+
+		Convey("StdLogger 可用于 http.Server#ErrorLog", func() {
+			buf.Reset()
+			l.StdLogger(ErrorLevel).Printf("http: TLS handshake error from %s", "127.0.0.1")
+			So(buf.String(), ShouldEqual, "[ERRO] http: TLS handshake error from 127.0.0.1\n")
+		})
+
+		Convey("低于 logger 级别的写入被忽略", func() {
+			buf.Reset()
+			l.Writer(TraceLevel).Write([]byte("noise\n"))
+			So(buf.String(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	Convey("RedirectStdLog 按行首的级别解析标准库日志", t, func() {
+		l, buf := newBufferLogger()
+		l.SetLevel("debug")
+		l.EnableCaller(0)
+
+		restore := RedirectStdLog(l, InfoLevel)
+		defer restore()
+
+		stdlog.Print("plain message")
+		So(buf.String(), ShouldStartWith, "[INFO] log/bridge_test.go:")
+		So(buf.String(), ShouldEndWith, " plain message\n")
+
+		l.DisableCaller()
+		buf.Reset()
+		stdlog.Print("[WARN] slow query")
+		stdlog.Print("error: connection refused")
+		stdlog.Print("level=debug cache miss")
+		stdlog.Print("fatal: not really")
+		stdlog.Print("[trace] too verbose")
+		So(buf.String(), ShouldEqual, "[WARN] slow query\n[ERRO] connection refused\n[DBUG] cache miss\n[FTAL] not really\n")
+
+		Convey("以级别名开头的普通句子保持原样", func() {
+			buf.Reset()
+			stdlog.Print("errors are not levels")
+			stdlog.Print("Error connecting to db")
+			stdlog.Print("Debug mode enabled")
+			stdlog.Print("WARNING disk is almost full")
+			stdlog.Print("[nope] unknown marker")
+			So(buf.String(), ShouldEqual, "[INFO] errors are not levels\n"+
+				"[INFO] Error connecting to db\n"+
+				"[INFO] Debug mode enabled\n"+
+				"[INFO] WARNING disk is almost full\n"+
+				"[INFO] [nope] unknown marker\n")
+		})
+	})
+}
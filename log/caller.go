@@ -0,0 +1,122 @@
+package log
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tm-ad/g-base/util/pio"
+)
+
+// Caller describes the function which printed a `Log`,
+// see `Logger#EnableCaller`.
+type Caller struct {
+	// File is the full path of the source file.
+	File string
+	// Line is the line number in the File.
+	Line int
+	// Function is the full name of the function, i.e "github.com/tm-ad/app/db.(*Pool).Get".
+	Function string
+}
+
+// String returns the "dir/file.go:line" of the caller,
+// the File is trimmed to its last directory.
+func (c *Caller) String() string {
+	return trimPath(c.File) + ":" + strconv.Itoa(c.Line)
+}
+
+func trimPath(file string) string {
+	idx := strings.LastIndexByte(file, '/')
+	if idx == -1 {
+		return file
+	}
+	if idx = strings.LastIndexByte(file[:idx], '/'); idx == -1 {
+		return file
+	}
+	return file[idx+1:]
+}
+
+// packagePrefix is the prefix of the functions of this package,
+// their frames are skipped when looking for the caller.
+var packagePrefix = func() string {
+	name := runtime.FuncForPC(reflect.ValueOf(New).Pointer()).Name()
+	return strings.TrimSuffix(name, "New")
+}()
+
+// bridgePrefixes are the prefixes of the functions which forward
+// the logs of the standard library's logger to a Logger, see `Logger#Writer`.
+var bridgePrefixes = []string{
+	"log.",
+	strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(pio.NewPrinter).Pointer()).Name(), "NewPrinter"),
+}
+
+// maxCallerDepth is the maximum number of frames that are inspected to find a caller.
+const maxCallerDepth = 32
+
+// isPackageFrame reports whether the "function" belongs to this package
+// or to the standard library's logger and the output adapters which forward to it.
+func isPackageFrame(function string) bool {
+	if strings.HasPrefix(function, packagePrefix) {
+		return true
+	}
+	for _, prefix := range bridgePrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// findCaller returns the first frame outside of this package,
+// after skipping "skip" more frames.
+func findCaller(skip int) *Caller {
+	var pcs [maxCallerDepth]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !isPackageFrame(frame.Function) {
+			if skip <= 0 {
+				return &Caller{
+					File:     frame.File,
+					Line:     frame.Line,
+					Function: frame.Function,
+				}
+			}
+			skip--
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// EnableCaller makes "l" annotate its logs with the file, the line and the
+// function of their caller, which is the first function outside of this package.
+//
+// The "skip" is the number of extra frames to skip,
+// it should be set by the helpers that wrap a Logger, i.e 1 for a single wrapper.
+//
+// Returns itself.
+func (l *Logger) EnableCaller(skip int) *Logger {
+	l.mu.Lock()
+	l.caller = true
+	l.callerSkip = skip
+	l.mu.Unlock()
+
+	return l
+}
+
+// DisableCaller stops the caller annotation of `EnableCaller`.
+//
+// Returns itself.
+func (l *Logger) DisableCaller() *Logger {
+	l.mu.Lock()
+	l.caller = false
+	l.mu.Unlock()
+
+	return l
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"sort"
+	"sync"
+)
+
+// loggerMap caches the named children of a Logger.
+type loggerMap struct {
+	mu    sync.RWMutex
+	Items map[string]*Logger
+}
+
+func newLoggerMap() *loggerMap {
+	return &loggerMap{
+		Items: make(map[string]*Logger),
+	}
+}
+
+// get returns the logger registered with "name".
+func (m *loggerMap) get(name string) (*Logger, bool) {
+	m.mu.RLock()
+	l, ok := m.Items[name]
+	m.mu.RUnlock()
+	return l, ok
+}
+
+// getOrAdd returns the logger registered with "name",
+// if not found then it creates and stores a new one through "factory".
+func (m *loggerMap) getOrAdd(name string, factory func() *Logger) *Logger {
+	if l, ok := m.get(name); ok {
+		return l
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.Items[name]; ok {
+		return l
+	}
+
+	l := factory()
+	m.Items[name] = l
+	return l
+}
+
+// list returns the loggers sorted by their names.
+func (m *loggerMap) list() []*Logger {
+	m.mu.RLock()
+	names := make([]string, 0, len(m.Items))
+	for name := range m.Items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]*Logger, 0, len(names))
+	for _, name := range names {
+		items = append(items, m.Items[name])
+	}
+	m.mu.RUnlock()
+
+	return items
+}
+
+// Child returns the named child of "l", it's created on the first call
+// and the next calls with the same "name" return the same Logger.
+//
+// The child inherits the level, the time format, the encoder, the outputs
+// and the fields of "l", its prefix is the prefix of "l" followed by "name".
+// Children follow the `SetLevel` calls of their parent until
+// their own level is set through `SetLevel`.
+func (l *Logger) Child(name string) *Logger {
+	return l.getChildren().getOrAdd(name, func() *Logger {
+		c := l.clone()
+		c.parent = l
+		c.name = name
+		if l.name != "" {
+			c.name = l.name + "." + name
+		}
+		c.Prefix = childPrefix(c.Prefix, name)
+		return c
+	})
+}
+
+// Name returns the dot-separated path of a child Logger,
+// i.e "http.router", it's empty for the root loggers.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// Parent returns the Logger that created "l" through `Child`,
+// or nil for the root loggers.
+func (l *Logger) Parent() *Logger {
+	return l.parent
+}
+
+// Children returns the named children of "l" sorted by their name.
+func (l *Logger) Children() []*Logger {
+	return l.getChildren().list()
+}
+
+func (l *Logger) getChildren() *loggerMap {
+	l.once.Do(func() {
+		if l.children == nil {
+			l.children = newLoggerMap()
+		}
+	})
+	return l.children
+}
+
+// childPrefix returns a new prefix which adds the "name" to the parent's "prefix",
+// i.e "app: " and "db" results to "app: db: ".
+func childPrefix(prefix []byte, name string) []byte {
+	p := make([]byte, 0, len(prefix)+len(name)+2)
+	p = append(p, prefix...)
+	if n := len(p); n > 0 && p[n-1] != ' ' {
+		p = append(p, ": "...)
+	}
+	p = append(p, name...)
+	if n := len(name); n > 0 && name[n-1] != ' ' {
+		p = append(p, ": "...)
+	}
+	return p
+}
+
+// inheritLevel sets the "level" of "l" and its children,
+// unless "l" has set its own level.
+func (l *Logger) inheritLevel(level Level) {
+	l.mu.Lock()
+	if l.ownLevel {
+		l.mu.Unlock()
+		return
+	}
+	l.Level = level
+	l.mu.Unlock()
+
+	for _, c := range l.Children() {
+		c.inheritLevel(level)
+	}
+}
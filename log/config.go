@@ -0,0 +1,392 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tm-ad/g-base/util"
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes a Logger for `NewFromConfig`, it can be loaded
+// from a JSON or YAML file through `LoadConfig` and from the environment through `LoadEnvConfig`, i.e
+//
+//	level: info
+//	encoder: text
+//	outputs:
+//	  - type: stdout
+//	  - type: file
+//	    root: ./logs
+//	    name: app
+//	    encoder: json
+//	profiles:
+//	  development:
+//	    level: debug
+//	  production:
+//	    encoder: json
+type Config struct {
+	// Level is the level name, defaults to "info".
+	Level string `json:"level" yaml:"level"`
+	// TimeFormat is the time layout of the text logs, an empty string disables the time,
+	// the default format of `New` is used when it's not set.
+	TimeFormat *string `json:"timeFormat" yaml:"timeFormat"`
+	// Prefix is the prefix of the logs.
+	Prefix string `json:"prefix" yaml:"prefix"`
+	// Encoder is the name of an encoder, see `RegisterEncoder`, defaults to "text".
+	Encoder string `json:"encoder" yaml:"encoder"`
+	// Outputs are the outputs of the Logger, defaults to the standard output.
+	Outputs []OutputConfig `json:"outputs" yaml:"outputs"`
+	// Profiles are overlays of the Config by the `util.GOENV()` value,
+	// i.e "development", "testing" and "production", the fields they set override the base ones
+	// and the environment variables of `LoadEnvConfig` override them.
+	Profiles map[string]Config `json:"profiles" yaml:"profiles"`
+}
+
+// OutputConfig describes an output of a `Config`.
+type OutputConfig struct {
+	// Type is "stdout", "stderr" or "file".
+	Type string `json:"type" yaml:"type"`
+	// Encoder is the name of the encoder of this output,
+	// defaults to the encoder of the Logger.
+	Encoder string `json:"encoder" yaml:"encoder"`
+	// Level, if not empty, limits this output to the logs of this level and the more important ones.
+	Level string `json:"level" yaml:"level"`
+
+	// Root is the directory of a "file" output, it must not be empty.
+	Root string `json:"root" yaml:"root"`
+	// Name is the base file name of a "file" output, defaults to "log".
+	Name string `json:"name" yaml:"name"`
+	// Pattern is the strftime pattern of a "file" output, defaults to "%Y-%m-%d".
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// RotationTime is the time between the rotations of a "file" output, defaults to 24h.
+	RotationTime Duration `json:"rotationTime" yaml:"rotationTime"`
+	// MaxAge is the max age of the files of a "file" output, defaults to 7 days.
+	MaxAge Duration `json:"maxAge" yaml:"maxAge"`
+}
+
+// Duration is a `time.Duration` which is read from strings like "24h" or from nanoseconds.
+type Duration time.Duration
+
+// UnmarshalJSON reads a duration string or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+// UnmarshalYAML reads a duration string or a number of nanoseconds.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	return d.set(v)
+}
+
+func (d *Duration) set(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(value)
+	case int:
+		*d = Duration(value)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("log: invalid duration %v", v)
+	}
+	return nil
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		"text":   TextEncoder,
+		"json":   JSONEncoder,
+		"logfmt": LogfmtEncoder,
+	}
+)
+
+// RegisterEncoder registers an encoder by its "name"
+// in order to be used by the `Config`, the built'n ones are "text", "json" and "logfmt".
+func RegisterEncoder(name string, enc Encoder) {
+	encodersMu.Lock()
+	encoders[name] = enc
+	encodersMu.Unlock()
+}
+
+func encoderByName(name string) (Encoder, error) {
+	encodersMu.RLock()
+	enc, ok := encoders[name]
+	encodersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("log: unknown encoder %q", name)
+	}
+	return enc, nil
+}
+
+// DefaultConfig returns the Config of the `util.GOENV()` profile:
+// colored text debug logs in development and testing, JSON info logs in production.
+func DefaultConfig() Config {
+	c := Config{
+		Level:   "info",
+		Encoder: "json",
+		Outputs: []OutputConfig{{Type: "stdout"}},
+	}
+	if util.Development() || util.Testing() {
+		c.Level = "debug"
+		c.Encoder = "text"
+	}
+	return c
+}
+
+// LoadConfig reads a Config from a JSON file, or from a YAML file
+// when the extension of the "filename" is ".yaml" or ".yml".
+func LoadConfig(filename string) (Config, error) {
+	var c Config
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &c)
+	default:
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("log: invalid config %s: %v", filename, err)
+	}
+	return c, nil
+}
+
+// The environment variables which are read by `LoadEnvConfig`.
+const (
+	EnvLevel            = "LOG_LEVEL"
+	EnvTimeFormat       = "LOG_TIME_FORMAT"
+	EnvPrefix           = "LOG_PREFIX"
+	EnvEncoder          = "LOG_ENCODER"
+	EnvOutputs          = "LOG_OUTPUTS"
+	EnvFileRoot         = "LOG_FILE_ROOT"
+	EnvFileName         = "LOG_FILE_NAME"
+	EnvFilePattern      = "LOG_FILE_PATTERN"
+	EnvFileRotationTime = "LOG_FILE_ROTATION_TIME"
+	EnvFileMaxAge       = "LOG_FILE_MAX_AGE"
+)
+
+// LoadEnvConfig returns the "base" Config overridden by its `util.GOENV()` profile
+// and then by the environment variables, so an explicit variable wins over the profile.
+// The returned Config has no profiles, they are already applied.
+//
+// The `EnvOutputs` is a comma-separated list of output types, i.e "stdout,file",
+// and the file outputs are configured by the `EnvFile*` variables.
+func LoadEnvConfig(base Config) (Config, error) {
+	c := base.resolve()
+
+	if v, ok := os.LookupEnv(EnvLevel); ok {
+		c.Level = v
+	}
+	if v, ok := os.LookupEnv(EnvTimeFormat); ok {
+		c.TimeFormat = &v
+	}
+	if v, ok := os.LookupEnv(EnvPrefix); ok {
+		c.Prefix = v
+	}
+	if v, ok := os.LookupEnv(EnvEncoder); ok {
+		c.Encoder = v
+	}
+
+	if v := util.Env(EnvOutputs); v != "" {
+		c.Outputs = nil
+		for _, typ := range strings.Split(v, ",") {
+			o := OutputConfig{Type: strings.TrimSpace(typ)}
+			if o.Type == "file" {
+				o.Root = util.Env(EnvFileRoot)
+				o.Name = util.Env(EnvFileName)
+				o.Pattern = util.Env(EnvFilePattern)
+				for env, d := range map[string]*Duration{EnvFileRotationTime: &o.RotationTime, EnvFileMaxAge: &o.MaxAge} {
+					if s := util.Env(env); s != "" {
+						if err := d.set(s); err != nil {
+							return c, fmt.Errorf("log: invalid %s: %v", env, err)
+						}
+					}
+				}
+			}
+			c.Outputs = append(c.Outputs, o)
+		}
+	}
+
+	return c, nil
+}
+
+// resolve returns the Config overridden by its `util.GOENV()` profile,
+// the returned Config has no profiles in order to not apply it twice.
+func (c Config) resolve() Config {
+	p, ok := c.Profiles[util.GOENV()]
+	c.Profiles = nil
+	if !ok {
+		return c
+	}
+
+	if p.Level != "" {
+		c.Level = p.Level
+	}
+	if p.TimeFormat != nil {
+		c.TimeFormat = p.TimeFormat
+	}
+	if p.Prefix != "" {
+		c.Prefix = p.Prefix
+	}
+	if p.Encoder != "" {
+		c.Encoder = p.Encoder
+	}
+	if len(p.Outputs) > 0 {
+		c.Outputs = p.Outputs
+	}
+	return c
+}
+
+// NewFromConfig returns a new Logger which is built by the "c" Config
+// and its `util.GOENV()` profile, the profile of a Config which is returned
+// by `LoadEnvConfig` is already applied.
+func NewFromConfig(c Config) (*Logger, error) {
+	c = c.resolve()
+
+	l := New()
+	if c.Level != "" {
+		level, err := l.ParseLevelStrict(c.Level)
+		if err != nil {
+			return nil, err
+		}
+		l.setLevel(level)
+	}
+	if c.TimeFormat != nil {
+		l.SetTimeFormat(*c.TimeFormat)
+	}
+	if c.Prefix != "" {
+		l.SetPrefix(c.Prefix)
+	}
+	if c.Encoder != "" {
+		enc, err := encoderByName(c.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		l.SetEncoder(enc)
+	}
+
+	if len(c.Outputs) == 0 {
+		return l, nil
+	}
+
+	var (
+		plain   []io.Writer
+		encoded []io.Writer
+		leveled []func()
+		files   []*RotateWriter
+	)
+	// the files of the previous outputs are closed when an output is invalid
+	fail := func(err error) (*Logger, error) {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	for _, o := range c.Outputs {
+		w, err := o.writer()
+		if err != nil {
+			return fail(err)
+		}
+		if f, ok := w.(*RotateWriter); ok {
+			files = append(files, f)
+		}
+
+		if o.Encoder != "" && o.Encoder != c.Encoder {
+			enc, err := encoderByName(o.Encoder)
+			if err != nil {
+				return fail(err)
+			}
+			w = EncodedOutput(enc, w)
+		}
+
+		if o.Level != "" {
+			level, err := l.ParseLevelStrict(o.Level)
+			if err != nil {
+				return fail(err)
+			}
+			leveled = append(leveled, func() { l.AddLevelOutput(FatalLevel, level, w) })
+			continue
+		}
+
+		if _, ok := w.(encodedWriter); ok {
+			encoded = append(encoded, w)
+		} else {
+			plain = append(plain, w)
+		}
+	}
+
+	// SetOutput detects the colors of the first plain writer.
+	if len(plain) > 0 {
+		l.SetOutput(plain[0])
+		plain = plain[1:]
+	} else {
+		l.SetOutput(NopOutput)
+	}
+	l.AddOutput(append(plain, encoded...)...)
+	for _, add := range leveled {
+		add()
+	}
+
+	return l, nil
+}
+
+// NewFromConfigFile returns a new Logger which is built by the Config
+// of the "filename", overridden by the environment variables, see `LoadEnvConfig`.
+// If the "filename" is empty then the `DefaultConfig` is used instead.
+func NewFromConfigFile(filename string) (*Logger, error) {
+	c := DefaultConfig()
+	if filename != "" {
+		var err error
+		if c, err = LoadConfig(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := LoadEnvConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(c)
+}
+
+func (o OutputConfig) writer() (io.Writer, error) {
+	switch o.Type {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		if o.Root == "" {
+			return nil, fmt.Errorf("log: the root of a file output is empty")
+		}
+		return newRotateFile(o.Root, defaultName(o.Name), o.Pattern,
+			defaultRotationTime(time.Duration(o.RotationTime)), defaultMaxAge(time.Duration(o.MaxAge)))
+	default:
+		return nil, fmt.Errorf("log: unknown output type %q", o.Type)
+	}
+}
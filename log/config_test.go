@@ -0,0 +1,128 @@
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+	"github.com/tm-ad/g-base/util"
+)
+
+// setenv sets the "name" environment variable and returns a function which restores it.
+func setenv(name, value string) (restore func()) {
+	old, ok := os.LookupEnv(name)
+	os.Setenv(name, value)
+	return func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	Convey("从 YAML 配置创建 logger，并应用 GO_ENV 对应的 profile", t, func() {
+		dir, err := ioutil.TempDir("", "log-config")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		filename := filepath.Join(dir, "log.yaml")
+		So(ioutil.WriteFile(filename, []byte(`
+level: info
+timeFormat: ""
+outputs:
+  - type: file
+    root: `+dir+`
+    name: app
+    pattern: "%Y"
+    rotationTime: 24h
+    maxAge: 48h
+profiles:
+  production:
+    level: warn
+    encoder: json
+`), 0644), ShouldBeNil)
+
+		defer setenv(util.DevelopmentEnv, util.PROD)()
+
+		c, err := LoadConfig(filename)
+		So(err, ShouldBeNil)
+		So(c.Outputs[0].MaxAge, ShouldEqual, Duration(48*time.Hour))
+
+		l, err := NewFromConfig(c)
+		So(err, ShouldBeNil)
+		So(l.Level, ShouldEqual, WarnLevel)
+
+		l.Info("hidden")
+		l.Warn("hello")
+		So(l.Flush(), ShouldBeNil)
+
+		files, _ := filepath.Glob(filepath.Join(dir, "app*.log"))
+		So(len(files), ShouldEqual, 1)
+		b, _ := ioutil.ReadFile(files[0])
+		So(string(b), ShouldStartWith, `{"time":`)
+		So(string(b), ShouldEndWith, `"level":"warn","message":"hello"}`+"\n")
+		So(strings.Count(string(b), "\n"), ShouldEqual, 1)
+	})
+
+	Convey("无效的输出返回错误", t, func() {
+		dir, err := ioutil.TempDir("", "log-config")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		_, err = NewFromConfig(Config{Outputs: []OutputConfig{
+			{Type: "file", Root: dir, Name: "app"},
+			{Type: "stderr", Encoder: "xml"},
+		}})
+		So(err, ShouldNotBeNil)
+
+		_, err = NewFromConfig(Config{Outputs: []OutputConfig{
+			{Type: "file", Root: dir, Name: "app"},
+			{Type: "stderr", Level: "nope"},
+		}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("环境变量覆盖配置", t, func() {
+		os.Setenv(EnvLevel, "debug")
+		os.Setenv(EnvOutputs, "stderr")
+		defer os.Unsetenv(EnvLevel)
+		defer os.Unsetenv(EnvOutputs)
+
+		c, err := LoadEnvConfig(Config{Level: "info", Encoder: "logfmt"})
+		So(err, ShouldBeNil)
+		So(c.Level, ShouldEqual, "debug")
+		So(c.Outputs, ShouldResemble, []OutputConfig{{Type: "stderr"}})
+
+		l, err := NewFromConfig(c)
+		So(err, ShouldBeNil)
+		So(l.Level, ShouldEqual, DebugLevel)
+
+		_, err = NewFromConfig(Config{Encoder: "xml"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("环境变量的优先级高于 GO_ENV 对应的 profile", t, func() {
+		defer setenv(util.DevelopmentEnv, util.PROD)()
+		defer setenv(EnvLevel, "debug")()
+
+		c, err := LoadEnvConfig(Config{
+			Level:    "info",
+			Encoder:  "text",
+			Profiles: map[string]Config{util.PROD: {Level: "warn", Encoder: "json"}},
+		})
+		So(err, ShouldBeNil)
+		So(c.Level, ShouldEqual, "debug")
+		So(c.Encoder, ShouldEqual, "json")
+
+		l, err := NewFromConfig(c)
+		So(err, ShouldBeNil)
+		So(l.Level, ShouldEqual, DebugLevel)
+	})
+}
@@ -0,0 +1,168 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type contextKey struct {
+	field string
+	key   interface{}
+}
+
+var (
+	contextKeysMu sync.RWMutex
+	contextKeys   []contextKey
+)
+
+// RegisterContextKey registers a `context.Context` key, i.e a request id key,
+// its value is attached as the "field" to the logs of `Logger#Ctx` and the `*Ctx` functions.
+//
+// Registering the same "field" again replaces its key.
+func RegisterContextKey(field string, key interface{}) {
+	contextKeysMu.Lock()
+	defer contextKeysMu.Unlock()
+
+	for i := range contextKeys {
+		if contextKeys[i].field == field {
+			contextKeys[i].key = key
+			return
+		}
+	}
+	contextKeys = append(contextKeys, contextKey{field: field, key: key})
+}
+
+// contextFields returns the registered values of the "ctx" in their registration order.
+func contextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	contextKeysMu.RLock()
+	defer contextKeysMu.RUnlock()
+
+	var fields []Field
+	for _, k := range contextKeys {
+		if v := ctx.Value(k.key); v != nil {
+			fields = append(fields, Field{Key: k.field, Value: v})
+		}
+	}
+	return fields
+}
+
+// Ctx returns a derived Logger which attaches the values of the registered
+// context keys as fields, see `RegisterContextKey`.
+// If the "ctx" has none of them then it returns "l" itself.
+//
+// Prefer the `*Ctx` functions, i.e `InfoCtx`, for a single log,
+// they attach the values without deriving a Logger.
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	fields := contextFields(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.withFields(fields)
+}
+
+// logCtx prints the "msg" with the registered values of the "ctx" attached,
+// they are added to the log itself, unlike `Ctx` no Logger is derived.
+func (l *Logger) logCtx(ctx context.Context, level Level, msg string, hasException bool) {
+	l.print(level, msg, l.NewLine, hasException, contextFields(ctx))
+}
+
+// LogCtx is like `Log` but it attaches the registered values of the "ctx".
+func (l *Logger) LogCtx(ctx context.Context, level Level, v ...interface{}) {
+	l.logCtx(ctx, level, fmt.Sprint(v...), hasException(v))
+}
+
+// LogCtxf is like `Logf` but it attaches the registered values of the "ctx".
+func (l *Logger) LogCtxf(ctx context.Context, level Level, format string, args ...interface{}) {
+	l.logCtx(ctx, level, fmt.Sprintf(format, args...), hasException(args))
+}
+
+// ErrorCtx is like `Error` but it attaches the registered values of the "ctx".
+func (l *Logger) ErrorCtx(ctx context.Context, v ...interface{}) {
+	l.LogCtx(ctx, ErrorLevel, v...)
+}
+
+// ErrorCtxf is like `Errorf` but it attaches the registered values of the "ctx".
+func (l *Logger) ErrorCtxf(ctx context.Context, format string, args ...interface{}) {
+	l.LogCtxf(ctx, ErrorLevel, format, args...)
+}
+
+// WarnCtx is like `Warn` but it attaches the registered values of the "ctx".
+func (l *Logger) WarnCtx(ctx context.Context, v ...interface{}) {
+	l.LogCtx(ctx, WarnLevel, v...)
+}
+
+// WarnCtxf is like `Warnf` but it attaches the registered values of the "ctx".
+func (l *Logger) WarnCtxf(ctx context.Context, format string, args ...interface{}) {
+	l.LogCtxf(ctx, WarnLevel, format, args...)
+}
+
+// InfoCtx is like `Info` but it attaches the registered values of the "ctx".
+func (l *Logger) InfoCtx(ctx context.Context, v ...interface{}) {
+	l.LogCtx(ctx, InfoLevel, v...)
+}
+
+// InfoCtxf is like `Infof` but it attaches the registered values of the "ctx".
+func (l *Logger) InfoCtxf(ctx context.Context, format string, args ...interface{}) {
+	l.LogCtxf(ctx, InfoLevel, format, args...)
+}
+
+// DebugCtx is like `Debug` but it attaches the registered values of the "ctx".
+func (l *Logger) DebugCtx(ctx context.Context, v ...interface{}) {
+	if l.GetLevel() >= DebugLevel {
+		l.LogCtx(ctx, DebugLevel, v...)
+	}
+}
+
+// DebugCtxf is like `Debugf` but it attaches the registered values of the "ctx".
+func (l *Logger) DebugCtxf(ctx context.Context, format string, args ...interface{}) {
+	if l.GetLevel() >= DebugLevel {
+		l.LogCtxf(ctx, DebugLevel, format, args...)
+	}
+}
+
+// TraceCtx is like `Trace` but it attaches the registered values of the "ctx".
+func (l *Logger) TraceCtx(ctx context.Context, v ...interface{}) {
+	if l.GetLevel() >= TraceLevel {
+		l.LogCtx(ctx, TraceLevel, v...)
+	}
+}
+
+// TraceCtxf is like `Tracef` but it attaches the registered values of the "ctx".
+func (l *Logger) TraceCtxf(ctx context.Context, format string, args ...interface{}) {
+	if l.GetLevel() >= TraceLevel {
+		l.LogCtxf(ctx, TraceLevel, format, args...)
+	}
+}
+
+type loggerContextKey struct{}
+
+var (
+	defaultLoggerOnce sync.Once
+	defaultLogger     *Logger
+)
+
+// NewContext returns a copy of the "ctx" which carries the "logger",
+// use `FromContext` to get it back.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the Logger carried by the "ctx" through `NewContext`,
+// if there isn't one then it returns a package-level Logger created by `New`.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok && l != nil {
+			return l
+		}
+	}
+
+	defaultLoggerOnce.Do(func() {
+		defaultLogger = New()
+	})
+	return defaultLogger
+}
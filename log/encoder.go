@@ -0,0 +1,185 @@
+package log
+
+import (
+	"github.com/tm-ad/g-base/util/pio"
+)
+
+// Encoder is the interface which is implemented by the
+// log formats, it converts a `*Log` into the bytes that are written to an output.
+//
+// The "colored" argument reports whether the output supports colors.
+// The trailing new line is added by the Logger, encoders should not add it.
+type Encoder interface {
+	Encode(l *Log, colored bool) ([]byte, error)
+}
+
+// EncoderFunc is an adapter which allows
+// a function to be used as an `Encoder`.
+type EncoderFunc func(l *Log, colored bool) ([]byte, error)
+
+// Encode calls f(l, colored).
+func (f EncoderFunc) Encode(l *Log, colored bool) ([]byte, error) {
+	return f(l, colored)
+}
+
+var (
+	// TextEncoder prints the prefix, the level text, the time, the "dir/file.go:line" caller,
+	// the message and the " key=value" fields in a single line, followed by
+	// the indented stack trace if any, it's the default encoder.
+	TextEncoder Encoder = EncoderFunc(encodeText)
+	// JSONEncoder prints each log as a JSON object through the marshalers of the printer,
+	// the `JSONMarshaler` is registered to the printers of the Logger.
+	JSONEncoder Encoder = jsonEncoder{}
+	// LogfmtEncoder prints each log as logfmt key/value pairs, i.e
+	// time=2019-08-01T10:00:00+08:00 level=info prefix=app msg="hello world" user=42
+	// the "caller" and "func" keys are added before the "msg" when the caller is enabled
+	// and the "stack" key, with the "function file:line" frames separated by ", ", is the last one.
+	// A field which key is one of these keys is written as "fields.key", i.e "fields.msg".
+	LogfmtEncoder Encoder = EncoderFunc(encodeLogfmt)
+)
+
+func encodeText(l *Log, colored bool) ([]byte, error) {
+	line := l.Logger.levelText(l.Level, colored)
+	if line != "" {
+		line += " "
+	}
+
+	if t := l.FormatTime(); t != "" {
+		line += t + " "
+	}
+	if c := l.Caller; c != nil {
+		line += c.String() + " "
+	}
+	line += l.Message
+
+	pref := l.Logger.Prefix
+	b := make([]byte, 0, len(pref)+len(line))
+	b = append(b, pref...)
+	b = append(b, line...)
+	b = appendFields(b, l.Fields)
+	return appendStack(b, l.Stack), nil
+}
+
+// jsonEncoder is the type of the `JSONEncoder`, the printers' hijacker
+// recognizes it in order to marshal the logs through `pio.Ctx#MarshalValue`.
+type jsonEncoder struct{}
+
+// Encode marshals the "l" through the `JSONMarshaler` directly.
+func (jsonEncoder) Encode(l *Log, colored bool) ([]byte, error) {
+	return JSONMarshaler(l)
+}
+
+// logfmtKeys are the keys which are written by the `LogfmtEncoder`.
+var logfmtKeys = []string{"time", "level", "prefix", "caller", "func", "msg", "stack"}
+
+func encodeLogfmt(l *Log, colored bool) ([]byte, error) {
+	b := make([]byte, 0, 128)
+	b = appendField(b, Field{Key: "time", Value: l.Time.Format(JSONTimeFormat)})
+	if meta, ok := l.Logger.LevelMeta(l.Level); ok && l.Level != DisableLevel {
+		b = append(b, ' ')
+		b = appendField(b, Field{Key: "level", Value: meta.Name})
+	}
+	if pref := l.Logger.Prefix; len(pref) > 0 {
+		b = append(b, ' ')
+		b = appendField(b, Field{Key: "prefix", Value: string(pref)})
+	}
+	if c := l.Caller; c != nil {
+		b = append(b, ' ')
+		b = appendField(b, Field{Key: "caller", Value: c.String()})
+		b = append(b, ' ')
+		b = appendField(b, Field{Key: "func", Value: c.Function})
+	}
+	b = append(b, ' ')
+	b = appendField(b, Field{Key: "msg", Value: l.Message})
+	for _, f := range l.Fields {
+		b = append(b, ' ')
+		b = appendField(b, Field{Key: fieldKey(f.Key, logfmtKeys), Value: f.Value})
+	}
+	if len(l.Stack) > 0 {
+		b = append(b, ' ')
+		b = appendField(b, Field{Key: "stack", Value: joinStack(l.Stack)})
+	}
+	return b, nil
+}
+
+// encoderHijacker returns a printer hijacker which encodes
+// the logs with "enc", or with the encoder of their Logger when "enc" is nil.
+func encoderHijacker(enc Encoder) pio.Hijacker {
+	return func(ctx *pio.Ctx) {
+		l, ok := ctx.Value.(*Log)
+		if !ok {
+			ctx.Next()
+			return
+		}
+
+		e := enc
+		if e == nil {
+			e = l.Logger.encoder()
+		}
+
+		var (
+			b   []byte
+			err error
+		)
+		if _, ok := e.(jsonEncoder); ok {
+			// let the marshalers of the printer, i.e the `JSONMarshaler`, encode it.
+			b, err = ctx.MarshalValue()
+			if err == pio.ErrSkipped || err == pio.ErrMarshalNotResponsible {
+				b, err = e.Encode(l, ctx.Printer.IsTerminal)
+			}
+		} else {
+			b, err = e.Encode(l, ctx.Printer.IsTerminal)
+		}
+		if err == nil && l.NewLine {
+			b = append(b, pio.NewLine...)
+		}
+
+		ctx.Store(b, err)
+		ctx.Next()
+	}
+}
+
+// we could use marshal inside Log but we don't have access to printer,
+// we could also use the .Handle with NopOutput too but
+// this way is faster:
+var logHijacker = encoderHijacker(nil)
+
+// SetEncoder sets the `Encoder` of the Logger's Printer,
+// outputs which are added through `EncodedOutput` keep their own encoder.
+//
+// Returns itself.
+func (l *Logger) SetEncoder(enc Encoder) *Logger {
+	l.mu.Lock()
+	l.Encoder = enc
+	l.mu.Unlock()
+
+	return l
+}
+
+// Format is the layout of the logs that a Logger prints, see `Logger#SetFormat`.
+type Format uint8
+
+const (
+	// TextFormat prints the logs through the `TextEncoder`, it's the default format.
+	TextFormat Format = iota
+	// JSONFormat prints each log as a single line JSON object through the `JSONEncoder`.
+	JSONFormat
+)
+
+// SetFormat sets the layout of the logs, `TextFormat` or `JSONFormat`,
+// it's a shortcut of `SetEncoder` with the `TextEncoder` or the `JSONEncoder`.
+//
+// Returns itself.
+func (l *Logger) SetFormat(f Format) *Logger {
+	if f == JSONFormat {
+		return l.SetEncoder(JSONEncoder)
+	}
+	return l.SetEncoder(TextEncoder)
+}
+
+func (l *Logger) encoder() Encoder {
+	if l.Encoder == nil {
+		return TextEncoder
+	}
+	return l.Encoder
+}
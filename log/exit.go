@@ -0,0 +1,74 @@
+package log
+
+import (
+	"os"
+	"sync"
+)
+
+// exitHandler holds the exit function and the exit hooks of a Logger,
+// it's shared between a Logger and the loggers derived from it.
+type exitHandler struct {
+	mu    sync.Mutex
+	fn    func(code int)
+	hooks []func()
+}
+
+func newExitHandler() *exitHandler {
+	return &exitHandler{fn: os.Exit}
+}
+
+// SetExitFunc replaces the function that the fatal logs call to exit, defaults to `os.Exit`.
+// It's useful to test the fatal paths, note that the caller continues
+// to run if "fn" returns.
+//
+// Returns itself.
+func (l *Logger) SetExitFunc(fn func(code int)) *Logger {
+	if fn == nil {
+		fn = os.Exit
+	}
+
+	e := l.dispatcher.exit
+	e.mu.Lock()
+	e.fn = fn
+	e.mu.Unlock()
+
+	return l
+}
+
+// RegisterExitHook registers a function which runs before the exit of a fatal log,
+// the hooks run in the order they were registered and before the outputs are flushed,
+// therefore they can still log.
+//
+// Returns itself.
+func (l *Logger) RegisterExitHook(hook func()) *Logger {
+	e := l.dispatcher.exit
+	e.mu.Lock()
+	e.hooks = append(e.hooks, hook)
+	e.mu.Unlock()
+
+	return l
+}
+
+// exit runs the exit hooks, flushes the outputs and exits with the "code".
+func (l *Logger) exit(code int) {
+	e := l.dispatcher.exit
+	e.mu.Lock()
+	hooks, fn := e.hooks, e.fn
+	e.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	l.Flush()
+	fn(code)
+}
+
+// Flush waits until the logs that were queued by an asynchronous Logger
+// are written and flushes the outputs which can be synced, i.e files and `RotateWriter`.
+//
+// It returns the first error of the outputs.
+func (l *Logger) Flush() error {
+	l.dispatcher.flushAsync()
+	return l.dispatcher.syncOutputs()
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Field is a typed key/value pair which is attached to a `Log`
+// and rendered after its `Message`.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns the "key=value" representation of the field.
+func (f Field) String() string {
+	return string(appendField(nil, f))
+}
+
+// Fields is a set of key/value pairs, see `Logger#WithFields`.
+type Fields map[string]interface{}
+
+// missingValue is used as the value of a dangling key passed to `Logger#With`.
+const missingValue = "(MISSING)"
+
+// reservedKeyPrefix is prepended to the key of a field which
+// conflicts with a key of the encoder, i.e "fields.time".
+const reservedKeyPrefix = "fields."
+
+// WithFields returns a derived Logger which attaches the "fields"
+// to every `Log` that it prints, after the fields of "l" itself.
+//
+// Keys are sorted in order to keep the output stable.
+func (l *Logger) WithFields(fields Fields) *Logger {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fs := make([]Field, 0, len(keys))
+	for _, k := range keys {
+		fs = append(fs, Field{Key: k, Value: fields[k]})
+	}
+
+	return l.withFields(fs)
+}
+
+// With returns a derived Logger which attaches the "keyvals"
+// to every `Log` that it prints, after the fields of "l" itself.
+//
+// The "keyvals" are alternating keys and values, i.e
+// `With("user", 42, "request", "abc")`, a `Field` may be passed
+// as a single element too. A key without a value is logged as "(MISSING)".
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	return l.withFields(fieldsOf(keyvals))
+}
+
+func (l *Logger) withFields(fields []Field) *Logger {
+	c := l.clone()
+	c.fields = mergeFields(c.fields, fields)
+	return c
+}
+
+// fieldsOf converts alternating keys and values to fields.
+func fieldsOf(keyvals []interface{}) []Field {
+	fields := make([]Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i++ {
+		if f, ok := keyvals[i].(Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+
+		if i+1 < len(keyvals) {
+			fields = append(fields, Field{Key: key, Value: keyvals[i+1]})
+			i++
+		} else {
+			fields = append(fields, Field{Key: key, Value: missingValue})
+		}
+	}
+	return fields
+}
+
+// mergeFields returns a new slice which contains the "base" fields
+// followed by the "fields", a field with an existing key replaces
+// the old value in place.
+func mergeFields(base []Field, fields []Field) []Field {
+	merged := make([]Field, len(base), len(base)+len(fields))
+	copy(merged, base)
+
+next:
+	for _, f := range fields {
+		for i := range merged {
+			if merged[i].Key == f.Key {
+				merged[i].Value = f.Value
+				continue next
+			}
+		}
+		merged = append(merged, f)
+	}
+
+	// protect the shared slice from appends of the derived loggers.
+	return merged[:len(merged):len(merged)]
+}
+
+// appendFields appends the " key=value" pairs of "fields" to "b".
+func appendFields(b []byte, fields []Field) []byte {
+	for _, f := range fields {
+		b = append(b, ' ')
+		b = appendField(b, f)
+	}
+	return b
+}
+
+// fieldKey returns the "key" of a field, prefixed by "fields."
+// when it's one of the "reserved" keys of an encoder.
+func fieldKey(key string, reserved []string) string {
+	for _, r := range reserved {
+		if key == r {
+			return reservedKeyPrefix + key
+		}
+	}
+	return key
+}
+
+func appendField(b []byte, f Field) []byte {
+	b = appendValue(b, f.Key)
+	b = append(b, '=')
+	return appendValue(b, fieldValue(f.Value))
+}
+
+// fieldValue returns the string representation of a field value.
+func fieldValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return value
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// appendValue appends "s" to "b", quoted if it can't be read back as a single token.
+func appendValue(b []byte, s string) []byte {
+	if needsQuote(s) {
+		return strconv.AppendQuote(b, s)
+	}
+	return append(b, s...)
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
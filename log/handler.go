@@ -0,0 +1,46 @@
+package log
+
+// Handler is the signature implemented by callers
+// that want to intercept the logs before they are printed.
+//
+// A Handler can filter or consume a log by returning true,
+// in that case the next handlers and the default printer are skipped,
+// it can also enrich the log, i.e modify its `Message` or replace its `Fields`,
+// or forward it to another sink and return false.
+//
+// The `Fields` are shared with the Logger and the loggers derived from it,
+// a handler must assign a new slice to them instead of editing them in place, i.e
+//
+// value.Fields = append(value.Fields[:len(value.Fields):len(value.Fields)], log.Field{Key: "host", Value: host})
+//
+// The *Log is released after the print call, therefore
+// it should not be used after the handler returned.
+type Handler func(value *Log) (handled bool)
+
+// Handle adds a log handler, the handlers are executed in the order
+// they were registered, loggers created later through
+// `Child`, `With` and `WithFields` inherit the handlers of "l".
+//
+// Returns itself.
+func (l *Logger) Handle(handler Handler) *Logger {
+	l.mu.Lock()
+	l.handlers = append(l.handlers, handler)
+	l.mu.Unlock()
+
+	return l
+}
+
+// handled reports whether one of the handlers consumed the "value".
+func (l *Logger) handled(value *Log) bool {
+	l.mu.Lock()
+	handlers := l.handlers
+	l.mu.Unlock()
+
+	for _, h := range handlers {
+		if h(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tm-ad/g-base/exceptions"
+	"github.com/tm-ad/g-base/util/pio"
+)
+
+// JSONTimeFormat is the time layout of the "time" key of JSON logs,
+// it doesn't depend on the `Logger#TimeFormat` in order to be machine-readable.
+var JSONTimeFormat = time.RFC3339Nano
+
+// JSONMarshaler is the `pio.Marshaler` which is registered to the printers of a Logger,
+// the `JSONEncoder` prints the logs through it. It marshals a `*Log` into a JSON object, i.e
+// {"time":"2019-08-01T10:00:00+08:00","level":"info","prefix":"app ","message":"hello","user":42}
+//
+// The "caller" and "func" keys are added when the caller is enabled,
+// the fields are written as top-level keys in their order, followed by the
+// "stack" array of {"func","file","line"} objects if any,
+// the "level" key is omitted for logs printed through `Print` functions.
+//
+// A field which key is one of the keys above is written as "fields.key", i.e "fields.time",
+// so the object never has duplicate keys.
+var JSONMarshaler = pio.MarshalerFunc(func(v interface{}) ([]byte, error) {
+	l, ok := v.(*Log)
+	if !ok {
+		return nil, pio.ErrMarshalNotResponsible
+	}
+
+	b := make([]byte, 0, 128)
+	b = append(b, '{')
+	b = appendJSONPair(b, "time", l.Time.Format(JSONTimeFormat))
+	if meta, ok := l.Logger.LevelMeta(l.Level); ok && l.Level != DisableLevel {
+		b = append(b, ',')
+		b = appendJSONPair(b, "level", meta.Name)
+	}
+	if pref := l.Logger.Prefix; len(pref) > 0 {
+		b = append(b, ',')
+		b = appendJSONPair(b, "prefix", string(pref))
+	}
+	if c := l.Caller; c != nil {
+		b = append(b, ',')
+		b = appendJSONPair(b, "caller", c.String())
+		b = append(b, ',')
+		b = appendJSONPair(b, "func", c.Function)
+	}
+	b = append(b, ',')
+	b = appendJSONPair(b, "message", l.Message)
+	for _, f := range l.Fields {
+		b = append(b, ',')
+		b = appendJSONPair(b, fieldKey(f.Key, jsonKeys), f.Value)
+	}
+	if len(l.Stack) > 0 {
+		b = append(b, ',')
+		b = appendJSONPair(b, "stack", jsonStack(l.Stack))
+	}
+	b = append(b, '}')
+
+	return b, nil
+})
+
+// jsonKeys are the keys which are written by the `JSONMarshaler`.
+var jsonKeys = []string{"time", "level", "prefix", "caller", "func", "message", "stack"}
+
+type jsonFrame struct {
+	Function string `json:"func"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+func jsonStack(stack []exceptions.Frame) []jsonFrame {
+	frames := make([]jsonFrame, len(stack))
+	for i, f := range stack {
+		frames[i] = jsonFrame{Function: f.Function, File: f.File, Line: f.Line}
+	}
+	return frames
+}
+
+func appendJSONPair(b []byte, key string, value interface{}) []byte {
+	k, _ := json.Marshal(key)
+	b = append(b, k...)
+	b = append(b, ':')
+	return append(b, jsonValue(value)...)
+}
+
+// jsonValue encodes "v", errors and values which
+// can't be encoded are written as strings.
+func jsonValue(v interface{}) []byte {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fieldValue(v))
+	}
+	return b
+}
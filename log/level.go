@@ -2,27 +2,42 @@ package log
 
 import "github.com/tm-ad/g-base/util/pio"
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
 
 // Level is a number which defines the log level.
 type Level uint32
 
 // The available built'n log levels, users can add or modify a level via `Levels` field.
+//
+// The values are explicit because they are stored and configured as numbers,
+// a new level must not change the value of the existing ones.
+// Note that the `PanicLevel` has moved the error, warn, info and debug levels
+// up by one, i.e the `InfoLevel` was 4 and it's 5 now.
 const (
 	// DisableLevel will disable the printer.
-	DisableLevel Level = iota
+	DisableLevel Level = 0
 	// FatalLevel will `os.Exit(1)` no matter the level of the logger.
-	// If the logger's level is fatal, error, warn, info or debug
+	// If the logger's level is fatal, panic, error, warn, info or debug
+	// then it will print the log message too.
+	FatalLevel Level = 1
+	// PanicLevel will panic with an `exceptions.Exception` no matter the level of the logger.
+	// If the logger's level is panic, error, warn, info or debug
 	// then it will print the log message too.
-	FatalLevel
+	PanicLevel Level = 2
 	// ErrorLevel will print only errors.
-	ErrorLevel
+	ErrorLevel Level = 3
 	// WarnLevel will print errors and warnings.
-	WarnLevel
+	WarnLevel Level = 4
 	// InfoLevel will print errors, warnings and infos.
-	InfoLevel
-	// DebugLevel will print on any level, fatals, errors, warnings, infos and debug logs.
-	DebugLevel
+	InfoLevel Level = 5
+	// DebugLevel will print on any level, fatals, panics, errors, warnings, infos and debug logs.
+	DebugLevel Level = 6
+	// TraceLevel will print everything, including the trace logs which are more verbose than the debug ones.
+	TraceLevel Level = 7
 )
 
 // Levels contains the levels and their
@@ -42,6 +57,11 @@ var Levels = map[Level]*LevelMetadata{
 		// white foreground but red background, it's nice
 		ColorfulText: pio.RedBackground("[FTAL]"),
 	},
+	PanicLevel: {
+		Name:         "panic",
+		RawText:      "[PANC]",
+		ColorfulText: pio.RedBackground("[PANC]"),
+	},
 	ErrorLevel: {
 		Name:         "error",
 		RawText:      "[ERRO]",
@@ -63,24 +83,45 @@ var Levels = map[Level]*LevelMetadata{
 		RawText:      "[DBUG]",
 		ColorfulText: pio.Yellow("[DBUG]"),
 	},
+	TraceLevel: {
+		Name:         "trace",
+		RawText:      "[TRCE]",
+		ColorfulText: pio.Gray("[TRCE]"),
+	},
 }
 
 // ParseLevel returns a `golog.Level` from a string level.
 // Note that all existing log levels (name, prefix and color) can be customized
 // and new one can be added by the package-level `golog.Levels` map variable.
+//
+// An unknown "levelName" returns the `DisableLevel`, see `ParseLevelStrict`.
 func ParseLevel(levelName string) Level {
-	for level, meta := range Levels {
+	level, _ := ParseLevelStrict(levelName)
+	return level
+}
+
+// ParseLevelStrict is like `ParseLevel` but
+// an unknown "levelName" returns the `DisableLevel` and an error.
+func ParseLevelStrict(levelName string) (Level, error) {
+	if level, ok := parseLevel(Levels, levelName); ok {
+		return level, nil
+	}
+	return DisableLevel, fmt.Errorf("log: unknown level %q", levelName)
+}
+
+func parseLevel(levels map[Level]*LevelMetadata, levelName string) (Level, bool) {
+	for level, meta := range levels {
 		if meta.Name == levelName {
-			return level
+			return level, true
 		}
 
 		for _, altName := range meta.AlternativeNames {
 			if altName == levelName {
-				return level
+				return level, true
 			}
 		}
 	}
-	return DisableLevel
+	return DisableLevel, false
 }
 
 // LevelMetadata describes the information
@@ -198,3 +239,98 @@ var (
 		return ""
 	}
 )
+
+// levelRegistry holds the levels that are registered to a Logger,
+// the lookups fall back to the registry of its parent and then to the `Levels`.
+type levelRegistry struct {
+	mu     sync.RWMutex
+	parent *levelRegistry
+	levels map[Level]*LevelMetadata
+}
+
+func newLevelRegistry(parent *levelRegistry) *levelRegistry {
+	return &levelRegistry{parent: parent}
+}
+
+func (r *levelRegistry) set(level Level, meta *LevelMetadata) {
+	r.mu.Lock()
+	if r.levels == nil {
+		r.levels = make(map[Level]*LevelMetadata)
+	}
+	r.levels[level] = meta
+	r.mu.Unlock()
+}
+
+// own returns the metadata registered to "r" or to its parents.
+func (r *levelRegistry) own(level Level) (*LevelMetadata, bool) {
+	for ; r != nil; r = r.parent {
+		r.mu.RLock()
+		meta, ok := r.levels[level]
+		r.mu.RUnlock()
+		if ok {
+			return meta, true
+		}
+	}
+	return nil, false
+}
+
+func (r *levelRegistry) get(level Level) (*LevelMetadata, bool) {
+	if meta, ok := r.own(level); ok {
+		return meta, true
+	}
+	meta, ok := Levels[level]
+	return meta, ok
+}
+
+func (r *levelRegistry) parse(levelName string) (Level, error) {
+	for ; r != nil; r = r.parent {
+		r.mu.RLock()
+		level, ok := parseLevel(r.levels, levelName)
+		r.mu.RUnlock()
+		if ok {
+			return level, nil
+		}
+	}
+	return ParseLevelStrict(levelName)
+}
+
+// RegisterLevel adds or overrides a level of "l" and of its children,
+// without modifying the package-level `Levels`, i.e
+//
+// const NoticeLevel = log.TraceLevel + 1
+// l.RegisterLevel(NoticeLevel, &log.LevelMetadata{Name: "notice", RawText: "[NOTE]", ColorfulText: pio.Blue("[NOTE]")})
+// l.Log(NoticeLevel, "hello")
+//
+// Returns itself.
+func (l *Logger) RegisterLevel(level Level, meta *LevelMetadata) *Logger {
+	l.levels.set(level, meta)
+	return l
+}
+
+// LevelMeta returns the metadata of the "level",
+// the levels registered through `RegisterLevel` take precedence over the `Levels`.
+func (l *Logger) LevelMeta(level Level) (*LevelMetadata, bool) {
+	return l.levels.get(level)
+}
+
+// ParseLevel is like the package-level `ParseLevel`
+// but it knows the levels registered through `RegisterLevel` too.
+func (l *Logger) ParseLevel(levelName string) Level {
+	level, _ := l.levels.parse(levelName)
+	return level
+}
+
+// ParseLevelStrict is like the package-level `ParseLevelStrict`
+// but it knows the levels registered through `RegisterLevel` too.
+func (l *Logger) ParseLevelStrict(levelName string) (Level, error) {
+	return l.levels.parse(levelName)
+}
+
+// levelText returns the text of the "level",
+// the `GetTextForLevel` is used for the levels which aren't registered to "l".
+func (l *Logger) levelText(level Level, enableColor bool) string {
+	if meta, ok := l.levels.own(level); ok {
+		return meta.Text(enableColor)
+	}
+	return GetTextForLevel(level, enableColor)
+}
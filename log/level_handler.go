@@ -0,0 +1,131 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// levelState is the JSON representation of a Logger for the `LevelHandler`.
+type levelState struct {
+	Logger string `json:"logger"`
+	Level  string `json:"level"`
+}
+
+type levelRequest struct {
+	Logger string `json:"logger"`
+	Level  string `json:"level"`
+}
+
+type levelHandler struct {
+	root *Logger
+}
+
+// LevelHandler returns an `http.Handler` which exposes the levels
+// of the "root" Logger and of its named children, i.e
+// http.Handle("/debug/log/level", log.LevelHandler(logger))
+//
+// GET responds with the list of the loggers, i.e
+// [{"logger":"","level":"info"},{"logger":"db","level":"debug"}]
+// the "logger" query parameter selects a single logger by its `Name`.
+//
+// PUT sets the level of a logger, the "logger" and "level" are read from
+// a JSON body, i.e {"logger":"db","level":"debug"}, or from the query parameters.
+// The "logger" defaults to the "root", the loggers derived from it, i.e through `Child` and `With`,
+// which haven't set their own level follow the new level. It responds with the new state of the loggers.
+func LevelHandler(root *Logger) http.Handler {
+	return &levelHandler{root: root}
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		name := r.URL.Query().Get("logger")
+		l := h.find(name)
+		if l == nil {
+			http.Error(w, "logger "+name+" not found", http.StatusNotFound)
+			return
+		}
+
+		if name == "" {
+			h.respond(w, h.states(l, nil))
+		} else {
+			h.respond(w, []levelState{h.state(l)})
+		}
+	case http.MethodPut:
+		req := levelRequest{
+			Logger: r.URL.Query().Get("logger"),
+			Level:  r.URL.Query().Get("level"),
+		}
+		if r.Body != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		l := h.find(req.Logger)
+		if l == nil {
+			http.Error(w, "logger "+req.Logger+" not found", http.StatusNotFound)
+			return
+		}
+
+		level, err := l.ParseLevelStrict(req.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		l.setLevel(level)
+		h.respond(w, h.states(l, nil))
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// find returns the descendant of the root with the dot-separated "name",
+// it doesn't create children.
+func (h *levelHandler) find(name string) *Logger {
+	l := h.root
+	if rootName := l.Name(); rootName != "" {
+		if name == rootName {
+			return l
+		}
+		name = strings.TrimPrefix(name, rootName+".")
+	}
+	if name == "" {
+		return l
+	}
+
+	for _, part := range strings.Split(name, ".") {
+		c, ok := l.getChildren().get(part)
+		if !ok {
+			return nil
+		}
+		l = c
+	}
+	return l
+}
+
+func (h *levelHandler) state(l *Logger) levelState {
+	s := levelState{Logger: l.Name()}
+	if meta, ok := l.LevelMeta(l.GetLevel()); ok {
+		s.Level = meta.Name
+	}
+	return s
+}
+
+// states returns the state of "l" and of its descendants.
+func (h *levelHandler) states(l *Logger, states []levelState) []levelState {
+	states = append(states, h.state(l))
+	for _, c := range l.Children() {
+		states = h.states(c, states)
+	}
+	return states
+}
+
+func (h *levelHandler) respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
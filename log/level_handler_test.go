@@ -0,0 +1,88 @@
+package log_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+func TestLevelHandler(t *testing.T) {
+	Convey("LevelHandler 可以查询和修改 logger 及其子 logger 的等级", t, func() {
+		l, _ := newBufferLogger()
+		db := l.Child("db")
+		l.Child("http")
+		db.Child("pool")
+
+		srv := httptest.NewServer(LevelHandler(l))
+		defer srv.Close()
+
+		get := func(query string) (int, []map[string]string) {
+			resp, err := http.Get(srv.URL + query)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			var states []map[string]string
+			json.NewDecoder(resp.Body).Decode(&states)
+			return resp.StatusCode, states
+		}
+		put := func(body string) int {
+			req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		code, states := get("")
+		So(code, ShouldEqual, http.StatusOK)
+		So(len(states), ShouldEqual, 4)
+		So(states[1], ShouldResemble, map[string]string{"logger": "db", "level": "info"})
+
+		So(put(`{"logger":"db","level":"debug"}`), ShouldEqual, http.StatusOK)
+		So(db.Level, ShouldEqual, DebugLevel)
+		So(db.Child("pool").Level, ShouldEqual, DebugLevel)
+		So(l.Level, ShouldEqual, InfoLevel)
+
+		_, states = get("?logger=db.pool")
+		So(states, ShouldResemble, []map[string]string{{"logger": "db.pool", "level": "debug"}})
+
+		So(put(`{"logger":"db","level":"nope"}`), ShouldEqual, http.StatusBadRequest)
+		So(put(`{"logger":"cache","level":"debug"}`), ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("With 派生的 logger 跟随运行时修改的等级", t, func() {
+		l, buf := newBufferLogger()
+		api := l.With("component", "api")
+		db := l.With("tenant", "t1").Child("db")
+
+		srv := httptest.NewServer(LevelHandler(l))
+		defer srv.Close()
+
+		api.Debug("hidden")
+		So(buf.Len(), ShouldEqual, 0)
+
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"?level=debug", nil)
+		resp, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		So(api.GetLevel(), ShouldEqual, DebugLevel)
+		So(db.GetLevel(), ShouldEqual, DebugLevel)
+		api.Debug("visible")
+		db.Debugf("query %d", 1)
+		So(buf.String(), ShouldEqual, "[DBUG] visible component=api\ndb: [DBUG] query 1 tenant=t1\n")
+
+		Convey("设置了自身等级的派生 logger 不再跟随", func() {
+			api.SetLevel("error")
+			l.SetLevel("trace")
+			So(api.GetLevel(), ShouldEqual, ErrorLevel)
+			So(db.GetLevel(), ShouldEqual, TraceLevel)
+		})
+	})
+}
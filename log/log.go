@@ -18,6 +18,16 @@ type Log struct {
 	Level Level
 	// Message is the string reprensetation of the log's main body.
 	Message string
+	// Fields are the key/value pairs attached by `Logger#With` and `Logger#WithFields`,
+	// they are shared with the Logger, a `Handler` should replace them with a new slice
+	// instead of editing them in place.
+	Fields []Field
+	// Caller is the function which printed this Log,
+	// it's nil unless the `Logger#EnableCaller` is called.
+	Caller *Caller
+	// Stack is the trimmed call stack of this Log,
+	// it's nil unless the `Logger#EnableStacktrace` requires it.
+	Stack []exceptions.Frame
 	// NewLine returns false if this Log
 	// derives from a `Print` function,
 	// otherwise true if derives from a `Println`, `Error`, `Errorf`, `Warn`, etc...
@@ -40,9 +50,8 @@ func (l Log) FormatTime() string {
 func TipInDevelopment(msg string) {
 	if util.Development() {
 		if _loggerInDevelopment == nil {
-			_loggerInDevelopment = New()
+			_loggerInDevelopment = New().EnableStacktrace(WarnLevel)
 		}
 		_loggerInDevelopment.Warn(msg)
-		_loggerInDevelopment.Warn(exceptions.CallStack())
 	}
 }
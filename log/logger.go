@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"github.com/tm-ad/g-base/exceptions"
 	"github.com/tm-ad/g-base/util/pio"
 	"io"
 	"os"
@@ -14,6 +15,8 @@ type Logger struct {
 	Prefix     []byte
 	Level      Level
 	TimeFormat string
+	// Encoder encodes the logs of the Printer, defaults to `TextEncoder`.
+	Encoder Encoder
 	// if new line should be added on all log functions, even the `F`s.
 	// It defaults to true.
 	//
@@ -22,13 +25,24 @@ type Logger struct {
 	// Note that this will not override the time and level prefix,
 	// if you want to customize the log message please read the examples
 	// or navigate to: https://github.com/kataras/golog/issues/3#issuecomment-355895870.
-	NewLine bool
-	mu      sync.Mutex
-	Printer *pio.Printer
-	// handlers []Handler
-	once sync.Once
-	logs sync.Pool
-	// children *loggerMap
+	NewLine    bool
+	mu         sync.Mutex
+	Printer    *pio.Printer
+	handlers   []Handler
+	once       sync.Once
+	logs       sync.Pool
+	children   *loggerMap
+	parent     *Logger
+	levelFrom  *Logger
+	name       string
+	ownLevel   bool
+	ownOutput  bool
+	fields     []Field
+	caller     bool
+	callerSkip int
+	stackLevel Level
+	levels     *levelRegistry
+	dispatcher *dispatcher
 }
 
 // New returns a new golog with a default output to `os.Stdout`
@@ -38,13 +52,47 @@ func New() *Logger {
 		Level:      InfoLevel,
 		TimeFormat: "2006/01/02 15:04",
 		NewLine:    true,
-		Printer:    pio.NewPrinter("", os.Stdout).EnableDirectOutput().Hijack(logHijacker),
-		// children:   newLoggerMap(),
+		Printer:    newPrinter(os.Stdout),
+		children:   newLoggerMap(),
+		ownOutput:  true,
+		levels:     newLevelRegistry(nil),
+		dispatcher: newDispatcher(),
 	}
 }
 
-// acquireLog returns a new log fom the pool.
-func (l *Logger) acquireLog(level Level, msg string, withPrintln bool) *Log {
+// newPrinter returns the Printer of a Logger which writes to "w".
+func newPrinter(w io.Writer) *pio.Printer {
+	return pio.NewPrinter("", w).EnableDirectOutput().Hijack(logHijacker).Marshal(JSONMarshaler)
+}
+
+// clone returns a new Logger which shares the Printer and the outputs of "l"
+// until they're changed, see `ownOutputs`, and copies its settings and fields, it has no children.
+func (l *Logger) clone() *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return &Logger{
+		Prefix:     l.Prefix,
+		Level:      l.Level,
+		TimeFormat: l.TimeFormat,
+		Encoder:    l.Encoder,
+		NewLine:    l.NewLine,
+		Printer:    l.Printer,
+		handlers:   l.handlers[:len(l.handlers):len(l.handlers)],
+		children:   newLoggerMap(),
+		levelFrom:  l,
+		fields:     l.fields,
+		caller:     l.caller,
+		callerSkip: l.callerSkip,
+		stackLevel: l.stackLevel,
+		levels:     newLevelRegistry(l.levels),
+		dispatcher: l.dispatcher,
+	}
+}
+
+// acquireLog returns a new log fom the pool,
+// the "fields" are attached after the fields of "l".
+func (l *Logger) acquireLog(level Level, msg string, withPrintln, withStack bool, fields []Field) *Log {
 	log, ok := l.logs.Get().(*Log)
 	if !ok {
 		log = &Log{
@@ -55,53 +103,65 @@ func (l *Logger) acquireLog(level Level, msg string, withPrintln bool) *Log {
 	log.Time = time.Now()
 	log.Level = level
 	log.Message = msg
+	log.Fields = l.fields
+	if len(fields) > 0 {
+		log.Fields = mergeFields(l.fields, fields)
+	}
+	log.Caller = nil
+	if l.caller {
+		log.Caller = findCaller(l.callerSkip)
+	}
+	log.Stack = nil
+	if withStack {
+		log.Stack = stackTrace()
+	}
 	return log
 }
 
-// releaseLog Log releases a log instance back to the pool.
-func (l *Logger) releaseLog(log *Log) {
-	l.logs.Put(log)
-}
+// ownOutputs gives "l" a copy of the Printer and of the outputs
+// it shares with the logger it was derived from, before they're changed,
+// so that the changes don't reach that logger.
+func (l *Logger) ownOutputs() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-// we could use marshal inside Log but we don't have access to printer,
-// we could also use the .Handle with NopOutput too but
-// this way is faster:
-var logHijacker = func(ctx *pio.Ctx) {
-	l, ok := ctx.Value.(*Log)
-	if !ok {
-		ctx.Next()
+	if l.ownOutput {
 		return
 	}
+	l.ownOutput = true
 
-	line := GetTextForLevel(l.Level, ctx.Printer.IsTerminal)
-	if line != "" {
-		line += " "
-	}
-
-	if t := l.FormatTime(); t != "" {
-		line += t + " "
-	}
-	line += l.Message
-
-	var b []byte
-	if pref := l.Logger.Prefix; len(pref) > 0 {
-		b = append(pref, []byte(line)...)
-	} else {
-		b = []byte(line)
-	}
+	printer := newPrinter(l.Printer.Output)
+	printer.IsTerminal = l.Printer.IsTerminal
+	l.Printer = printer
+	l.dispatcher = l.dispatcher.fork()
+}
 
-	ctx.Store(b, nil)
-	ctx.Next()
+// releaseLog Log releases a log instance back to the pool.
+func (l *Logger) releaseLog(log *Log) {
+	l.logs.Put(log)
 }
 
 // NopOutput disables the output.
 var NopOutput = pio.NopOutput()
 
-// SetOutput overrides the Logger's Printer's Output with another `io.Writer`.
+// SetOutput overrides the Logger's Printer's Output with another `io.Writer`,
+// the outputs previously added through `EncodedOutput` and `AddLevelOutput` are removed.
+//
+// A logger derived through `Child` or `With` writes to the outputs of its parent until
+// it changes its own outputs, then the parent keeps its outputs and stops sharing them.
 //
 // Returns itself.
 func (l *Logger) SetOutput(w io.Writer) *Logger {
+	l.ownOutputs()
+	l.dispatcher.reset()
+	if ew, ok := w.(encodedWriter); ok {
+		l.Printer.SetOutput(NopOutput)
+		l.dispatcher.add(ew, &output{})
+		return l
+	}
+
 	l.Printer.SetOutput(w)
+	l.dispatcher.track(w)
 	return l
 }
 
@@ -110,9 +170,28 @@ func (l *Logger) SetOutput(w io.Writer) *Logger {
 // If one of the "writers" is not a terminal-based (i.e File)
 // then colors will be disabled for all outputs.
 //
+// Writers that are wrapped by `EncodedOutput` get a printer of their own,
+// therefore they keep their encoder and their colors.
+//
+// The writers are added to the inherited outputs of a derived logger,
+// not to the outputs of its parent, see `SetOutput`.
+//
 // Returns itself.
 func (l *Logger) AddOutput(writers ...io.Writer) *Logger {
-	l.Printer.AddOutput(writers...)
+	l.ownOutputs()
+	var plain []io.Writer
+	for _, w := range writers {
+		if ew, ok := w.(encodedWriter); ok {
+			l.dispatcher.add(ew, &output{})
+			continue
+		}
+		plain = append(plain, w)
+	}
+
+	if len(plain) > 0 {
+		l.Printer.AddOutput(plain...)
+		l.dispatcher.track(plain...)
+	}
 	return l
 }
 
@@ -160,137 +239,231 @@ func (l *Logger) DisableNewLine() *Logger {
 // Available level names are:
 // "disable"
 // "fatal"
+// "panic"
 // "error"
 // "warn"
 // "info"
 // "debug"
+// "trace"
+// and the names of the levels registered through `RegisterLevel`.
 //
 // Alternatively you can use the exported `Level` field, i.e `Level = golog.ErrorLevel`
 //
+// The new level is passed to the children which haven't set their own level.
+// An unknown "levelName" keeps the current level.
+//
 // Returns itself.
 func (l *Logger) SetLevel(levelName string) *Logger {
+	level, err := l.ParseLevelStrict(levelName)
+	if err != nil {
+		TipInDevelopment(err.Error())
+		return l
+	}
+
+	l.setLevel(level)
+	return l
+}
+
+// GetLevel returns the level of "l", the loggers derived through `Child`, `With`,
+// `WithFields` and `Ctx` return the current level of the Logger they derive from
+// until their own level is set through `SetLevel`, so the level changes of
+// a Logger, i.e through the `LevelHandler`, reach all the loggers derived from it.
+func (l *Logger) GetLevel() Level {
+	for {
+		l.mu.Lock()
+		level, from := l.Level, l.levelFrom
+		if l.ownLevel {
+			from = nil
+		}
+		l.mu.Unlock()
+
+		if from == nil {
+			return level
+		}
+		l = from
+	}
+}
+
+// setLevel sets the "level" as the own level of "l"
+// and passes it to the children.
+func (l *Logger) setLevel(level Level) {
 	l.mu.Lock()
-	l.Level = ParseLevel(levelName)
+	l.Level = level
+	l.ownLevel = true
 	l.mu.Unlock()
 
-	return l
+	for _, c := range l.Children() {
+		c.inheritLevel(level)
+	}
 }
 
-func (l *Logger) print(level Level, msg string, newLine bool) {
-	if l.Level >= level {
+func (l *Logger) print(level Level, msg string, newLine bool, hasException bool, fields []Field) {
+	l.record(level, msg, newLine, hasException, fields)
+	switch level {
+	case FatalLevel:
+		// if level was fatal we don't care about the logger's level, we'll exit.
+		l.exit(1)
+	case PanicLevel:
+		// the same for panic, the outputs are flushed in case it's not recovered.
+		l.Flush()
+		panic(exceptions.New(msg))
+	}
+}
+
+// record emits the log when the "level" is enabled and the log is sampled,
+// unlike `print` it never exits or panics.
+// The "fields" are attached to this log only, after the fields of "l".
+func (l *Logger) record(level Level, msg string, newLine bool, hasException bool, fields []Field) {
+	if l.GetLevel() >= level && l.dispatcher.sampler.sample(l, level, msg) {
 		// newLine passed here in order for handler to know
 		// if this message derives from Println and Leveled functions
 		// or by simply, Print.
-		log := l.acquireLog(level, msg, newLine)
-		// if not handled by one of the handler
-		// then print it as usual.
-		// if !l.handled(log) {
-		if newLine {
-			l.Printer.Println(log)
-		} else {
-			l.Printer.Print(log)
-		}
-		// }
+		l.emit(level, msg, newLine, l.wantsStack(level, hasException), fields)
+	}
+}
 
-		l.releaseLog(log)
+// emit passes a new log to the handlers and prints it,
+// the level and the sampling should be checked by the caller.
+func (l *Logger) emit(level Level, msg string, newLine bool, withStack bool, fields []Field) {
+	log := l.acquireLog(level, msg, newLine, withStack, fields)
+	// if not handled by one of the handler
+	// then print it as usual.
+	if !l.handled(log) {
+		l.write(log)
 	}
-	// if level was fatal we don't care about the logger's level, we'll exit.
-	if level == FatalLevel {
-		os.Exit(1)
+
+	l.releaseLog(log)
+}
+
+// write queues the "log" when the Logger is asynchronous,
+// otherwise it prints it immediately.
+func (l *Logger) write(log *Log) {
+	if !l.dispatcher.enqueue(log) {
+		l.writeNow(log)
 	}
 }
 
+// writeNow prints the "log" to the Printer and to the encoded outputs,
+// the new line is added by the encoders' hijacker.
+func (l *Logger) writeNow(log *Log) {
+	l.Printer.Print(log)
+	l.dispatcher.print(log)
+}
+
 // Print prints a log message without levels and colors.
 func (l *Logger) Print(v ...interface{}) {
-	l.print(DisableLevel, fmt.Sprint(v...), l.NewLine)
+	l.print(DisableLevel, fmt.Sprint(v...), l.NewLine, false, nil)
 }
 
 // Printf formats according to a format specifier and writes to `Printer#Output` without levels and colors.
 func (l *Logger) Printf(format string, args ...interface{}) {
-	l.print(DisableLevel, fmt.Sprintf(format, args...), l.NewLine)
+	l.print(DisableLevel, fmt.Sprintf(format, args...), l.NewLine, false, nil)
 }
 
 // Println prints a log message without levels and colors.
 // It adds a new line at the end, it overrides the `NewLine` option.
 func (l *Logger) Println(v ...interface{}) {
-	l.print(DisableLevel, fmt.Sprint(v...), true)
+	l.print(DisableLevel, fmt.Sprint(v...), true, false, nil)
 }
 
 // Log prints a leveled log message to the output.
 // This method can be used to use custom log levels if needed.
 // It adds a new line in the end.
 func (l *Logger) Log(level Level, v ...interface{}) {
-	l.print(level, fmt.Sprint(v...), l.NewLine)
+	l.print(level, fmt.Sprint(v...), l.NewLine, hasException(v), nil)
 }
 
 // Logf prints a leveled log message to the output.
 // This method can be used to use custom log levels if needed.
 // It adds a new line in the end.
 func (l *Logger) Logf(level Level, format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	l.Log(level, msg)
+	l.print(level, fmt.Sprintf(format, args...), l.NewLine, hasException(args), nil)
 }
 
 // Fatal `os.Exit(1)` exit no matter the level of the logger.
-// If the logger's level is fatal, error, warn, info or debug
+// If the logger's level is fatal, panic, error, warn, info or debug
 // then it will print the log message too.
+//
+// The exit hooks run and the outputs are flushed before the exit,
+// see `RegisterExitHook` and `SetExitFunc`.
 func (l *Logger) Fatal(v ...interface{}) {
 	l.Log(FatalLevel, v...)
 }
 
 // Fatalf will `os.Exit(1)` no matter the level of the logger.
-// If the logger's level is fatal, error, warn, info or debug
+// If the logger's level is fatal, panic, error, warn, info or debug
 // then it will print the log message too.
 func (l *Logger) Fatalf(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	l.Fatal(msg)
+	l.Logf(FatalLevel, format, args...)
+}
+
+// Panic panics with an `exceptions.Exception` of the message no matter the level of the logger.
+// If the logger's level is panic, error, warn, info or debug
+// then it will print the log message too.
+func (l *Logger) Panic(v ...interface{}) {
+	l.Log(PanicLevel, v...)
 }
 
-// Error will print only when logger's Level is error, warn, info or debug.
+// Panicf panics with an `exceptions.Exception` of the message no matter the level of the logger.
+// If the logger's level is panic, error, warn, info or debug
+// then it will print the log message too.
+func (l *Logger) Panicf(format string, args ...interface{}) {
+	l.Logf(PanicLevel, format, args...)
+}
+
+// Error will print only when logger's Level is error, warn, info, debug or trace.
 func (l *Logger) Error(v ...interface{}) {
 	l.Log(ErrorLevel, v...)
 }
 
-// Errorf will print only when logger's Level is error, warn, info or debug.
+// Errorf will print only when logger's Level is error, warn, info, debug or trace.
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	l.Error(msg)
+	l.Logf(ErrorLevel, format, args...)
 }
 
-// Warn will print when logger's Level is warn, info or debug.
+// Warn will print when logger's Level is warn, info, debug or trace.
 func (l *Logger) Warn(v ...interface{}) {
 	l.Log(WarnLevel, v...)
 }
 
-// Warnf will print when logger's Level is warn, info or debug.
+// Warnf will print when logger's Level is warn, info, debug or trace.
 func (l *Logger) Warnf(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	l.Warn(msg)
+	l.Logf(WarnLevel, format, args...)
 }
 
-// Info will print when logger's Level is info or debug.
+// Info will print when logger's Level is info, debug or trace.
 func (l *Logger) Info(v ...interface{}) {
 	l.Log(InfoLevel, v...)
 }
 
-// Infof will print when logger's Level is info or debug.
+// Infof will print when logger's Level is info, debug or trace.
 func (l *Logger) Infof(format string, args ...interface{}) {
-	msg := fmt.Sprintf(format, args...)
-	l.Info(msg)
+	l.Logf(InfoLevel, format, args...)
 }
 
-// Debug will print when logger's Level is debug.
+// Debug will print when logger's Level is debug or trace.
 func (l *Logger) Debug(v ...interface{}) {
 	l.Log(DebugLevel, v...)
 }
 
-// Debugf will print when logger's Level is debug.
+// Trace will print when logger's Level is trace.
+func (l *Logger) Trace(v ...interface{}) {
+	l.Log(TraceLevel, v...)
+}
+
+// Tracef will print when logger's Level is trace.
+func (l *Logger) Tracef(format string, args ...interface{}) {
+	if l.GetLevel() >= TraceLevel {
+		l.Logf(TraceLevel, format, args...)
+	}
+}
+
+// Debugf will print when logger's Level is debug or trace.
 func (l *Logger) Debugf(format string, args ...interface{}) {
 	// On debug mode don't even try to fmt.Sprintf if it's not required,
 	// this can be used to allow `Debugf` to be called without even the `fmt.Sprintf`'s
 	// performance cost if the logger doesn't allow debug logging.
-	if l.Level >= DebugLevel {
-		msg := fmt.Sprintf(format, args...)
-		l.Debug(msg)
+	if l.GetLevel() >= DebugLevel {
+		l.Logf(DebugLevel, format, args...)
 	}
 }
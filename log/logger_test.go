@@ -0,0 +1,456 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tm-ad/g-base/exceptions"
+	. "github.com/tm-ad/g-base/log"
+)
+
+func newBufferLogger() (*Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	l := New().SetTimeFormat("").SetOutput(buf)
+	return l, buf
+}
+
+func TestLogger_With(t *testing.T) {
+	Convey("With 派生的 logger 以 key=value 形式输出字段", t, func() {
+		l, buf := newBufferLogger()
+
+		l.With("user", 42, "request", "a b").Info("hello")
+		So(buf.String(), ShouldEqual, "[INFO] hello user=42 request=\"a b\"\n")
+
+		Convey("原 logger 不受影响", func() {
+			buf.Reset()
+			l.Info("hello")
+			So(buf.String(), ShouldEqual, "[INFO] hello\n")
+		})
+
+		Convey("缺少值的 key 输出为 (MISSING)", func() {
+			buf.Reset()
+			l.With("err", errors.New("boom"), "dangling").Error("failed")
+			So(buf.String(), ShouldEqual, "[ERRO] failed err=boom dangling=(MISSING)\n")
+		})
+	})
+}
+
+func TestLogger_WithFields(t *testing.T) {
+	Convey("WithFields 按 key 排序并覆盖已有字段", t, func() {
+		l, buf := newBufferLogger()
+
+		parent := l.With("tenant", "t1", "user", 1)
+		parent.WithFields(Fields{"user": 2, "b": true, "a": nil}).Info("hello")
+		So(buf.String(), ShouldEqual, "[INFO] hello tenant=t1 user=2 a=<nil> b=true\n")
+
+		buf.Reset()
+		parent.Info("again")
+		So(buf.String(), ShouldEqual, "[INFO] again tenant=t1 user=1\n")
+	})
+}
+
+func TestLogger_JSONEncoder(t *testing.T) {
+	Convey("JSON encoder 每行输出一个对象", t, func() {
+		l, buf := newBufferLogger()
+		l.SetPrefix("app ").SetEncoder(JSONEncoder)
+
+		l.With("user", 42, "err", errors.New("boom")).Warn("hello")
+
+		var got map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+		So(buf.String(), ShouldEndWith, "}\n")
+		So(got["level"], ShouldEqual, "warn")
+		So(got["prefix"], ShouldEqual, "app ")
+		So(got["message"], ShouldEqual, "hello")
+		So(got["user"], ShouldEqual, 42)
+		So(got["err"], ShouldEqual, "boom")
+		So(got["time"], ShouldNotBeEmpty)
+
+		Convey("与保留 key 同名的字段加上 fields. 前缀", func() {
+			buf.Reset()
+			l.With("time", "yesterday", "level", 1, "message", "m").Info("hello")
+
+			So(strings.Count(buf.String(), `"time":`), ShouldEqual, 1)
+			got = map[string]interface{}{}
+			So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+			So(got["time"], ShouldNotEqual, "yesterday")
+			So(got["level"], ShouldEqual, "info")
+			So(got["message"], ShouldEqual, "hello")
+			So(got["fields.time"], ShouldEqual, "yesterday")
+			So(got["fields.level"], ShouldEqual, 1)
+			So(got["fields.message"], ShouldEqual, "m")
+		})
+	})
+}
+
+func TestLogger_SetFormat(t *testing.T) {
+	Convey("SetFormat 切换文本与 JSON 格式", t, func() {
+		l, buf := newBufferLogger()
+
+		l.SetFormat(JSONFormat).Info("hello")
+		var got map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+		So(got["message"], ShouldEqual, "hello")
+
+		buf.Reset()
+		l.SetFormat(TextFormat).Info("hello")
+		So(buf.String(), ShouldEqual, "[INFO] hello\n")
+	})
+}
+
+func TestLogger_LogfmtEncoder(t *testing.T) {
+	Convey("logfmt encoder 输出 key=value 对", t, func() {
+		l, buf := newBufferLogger()
+		l.SetPrefix("app").SetEncoder(LogfmtEncoder)
+
+		l.With("user", 42).Info("hello world")
+		So(buf.String(), ShouldStartWith, "time=")
+		So(buf.String(), ShouldEndWith, " level=info prefix=app msg=\"hello world\" user=42\n")
+
+		Convey("与保留 key 同名的字段加上 fields. 前缀", func() {
+			buf.Reset()
+			l.With("msg", "m").Info("hello")
+			So(buf.String(), ShouldEndWith, " msg=hello fields.msg=m\n")
+		})
+	})
+}
+
+func TestLogger_EncodedOutput(t *testing.T) {
+	Convey("EncodedOutput 添加的输出使用自己的 encoder", t, func() {
+		l, text := newBufferLogger()
+		jsonBuf := &bytes.Buffer{}
+		l.AddOutput(EncodedOutput(JSONEncoder, jsonBuf))
+
+		l.With("user", 42).Info("hello")
+		So(text.String(), ShouldEqual, "[INFO] hello user=42\n")
+
+		var got map[string]interface{}
+		So(json.Unmarshal(jsonBuf.Bytes(), &got), ShouldBeNil)
+		So(got["message"], ShouldEqual, "hello")
+
+		Convey("SetOutput 会移除之前添加的输出", func() {
+			jsonBuf.Reset()
+			l.SetOutput(text)
+			l.Info("again")
+			So(jsonBuf.Len(), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestLogger_Child(t *testing.T) {
+	Convey("Child 继承父 logger 的设置并追加前缀", t, func() {
+		l, buf := newBufferLogger()
+		l.SetPrefix("app")
+
+		db := l.With("tenant", "t1").Child("db")
+		So(l.Child("db"), ShouldEqual, l.Child("db"))
+
+		db.Info("connected")
+		So(buf.String(), ShouldEqual, "app: db: [INFO] connected tenant=t1\n")
+		So(db.Child("pool").Name(), ShouldEqual, "db.pool")
+
+		Convey("未设置自身等级的子 logger 跟随父 logger", func() {
+			child := l.Child("cache")
+			l.SetLevel("debug")
+			So(child.Level, ShouldEqual, DebugLevel)
+
+			child.SetLevel("error")
+			l.SetLevel("info")
+			So(child.Level, ShouldEqual, ErrorLevel)
+			So(l.Level, ShouldEqual, InfoLevel)
+		})
+	})
+}
+
+func TestLogger_DerivedOutputs(t *testing.T) {
+	Convey("派生 logger 修改输出时不影响父 logger", t, func() {
+		l, buf := newBufferLogger()
+		errs := &bytes.Buffer{}
+		l.AddLevelOutput(ErrorLevel, FatalLevel, errs)
+
+		dbBuf := &bytes.Buffer{}
+		db := l.Child("db").AddOutput(dbBuf)
+		db.Error("query failed")
+		l.Error("boom")
+
+		So(buf.String(), ShouldEqual, "db: [ERRO] query failed\n[ERRO] boom\n")
+		So(errs.String(), ShouldEqual, "db: [ERRO] query failed\n[ERRO] boom\n")
+		So(dbBuf.String(), ShouldEqual, "db: [ERRO] query failed\n")
+
+		Convey("With 派生的 logger 调用 SetOutput 不会接管父 logger 的输出", func() {
+			buf.Reset()
+			errs.Reset()
+			reqBuf := &bytes.Buffer{}
+			req := l.With("req", 1).SetOutput(reqBuf)
+			req.Error("denied")
+			l.Error("boom")
+
+			So(reqBuf.String(), ShouldEqual, "[ERRO] denied req=1\n")
+			So(buf.String(), ShouldEqual, "[ERRO] boom\n")
+			So(errs.String(), ShouldEqual, "[ERRO] boom\n")
+		})
+	})
+}
+
+func TestLogger_Handle(t *testing.T) {
+	Convey("Handler 可以消费、过滤和丰富日志", t, func() {
+		l, buf := newBufferLogger()
+
+		var errs []string
+		l.Handle(func(value *Log) bool {
+			if value.Level == ErrorLevel {
+				errs = append(errs, value.Message)
+				return true
+			}
+			return false
+		}).Handle(func(value *Log) bool {
+			value.Message = "[enriched] " + value.Message
+			return false
+		})
+
+		l.Error("boom")
+		l.Info("hello")
+
+		So(errs, ShouldResemble, []string{"boom"})
+		So(buf.String(), ShouldEqual, "[INFO] [enriched] hello\n")
+
+		Convey("子 logger 继承 handler", func() {
+			buf.Reset()
+			l.Child("db").Error("lost")
+			So(errs, ShouldResemble, []string{"boom", "lost"})
+			So(buf.Len(), ShouldEqual, 0)
+		})
+	})
+}
+
+func logThroughWrapper(l *Logger, msg string) {
+	l.Infof("%s", msg)
+}
+
+func TestLogger_EnableCaller(t *testing.T) {
+	Convey("EnableCaller 记录日志调用者的文件、行号和函数", t, func() {
+		l, buf := newBufferLogger()
+		var caller *Caller
+		l.EnableCaller(0).Handle(func(value *Log) bool {
+			caller = value.Caller
+			return false
+		})
+
+		l.Info("hello")
+		So(caller, ShouldNotBeNil)
+		So(caller.Function, ShouldEndWith, "TestLogger_EnableCaller.func1")
+		So(buf.String(), ShouldStartWith, "[INFO] log/logger_test.go:")
+		So(buf.String(), ShouldEndWith, " hello\n")
+
+		Convey("skip 跳过包装函数", func() {
+			l.EnableCaller(1)
+			logThroughWrapper(l, "wrapped")
+			So(caller.Function, ShouldStartWith, "github.com/tm-ad/g-base/log_test.TestLogger_EnableCaller.")
+		})
+
+		Convey("JSON 输出 caller 和 func", func() {
+			buf.Reset()
+			l.SetEncoder(JSONEncoder).Error("boom")
+			var got map[string]interface{}
+			So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+			So(got["caller"], ShouldStartWith, "log/logger_test.go:")
+			So(got["func"], ShouldEqual, caller.Function)
+		})
+	})
+}
+
+func TestLogger_EnableStacktrace(t *testing.T) {
+	Convey("EnableStacktrace 为错误日志附加裁剪后的堆栈", t, func() {
+		l, buf := newBufferLogger()
+		l.EnableStacktrace(ErrorLevel)
+
+		l.Error("boom")
+		lines := strings.Split(buf.String(), "\n")
+		So(lines[0], ShouldEqual, "[ERRO] boom")
+		So(lines[1], ShouldStartWith, "\tgithub.com/tm-ad/g-base/log_test.TestLogger_EnableStacktrace.")
+		So(lines[2], ShouldContainSubstring, "log/logger_test.go:")
+		So(buf.String(), ShouldNotContainSubstring, "g-base/log.(*Logger)")
+		So(buf.String(), ShouldNotContainSubstring, "\truntime.")
+
+		Convey("info 日志没有堆栈", func() {
+			buf.Reset()
+			l.Info("hello")
+			So(buf.String(), ShouldEqual, "[INFO] hello\n")
+		})
+
+		Convey("参数中包含 exceptions.Exception 时附加堆栈", func() {
+			buf.Reset()
+			l.Infof("failed: %v", exceptions.New("boom"))
+			So(strings.Count(buf.String(), "\n"), ShouldBeGreaterThan, 1)
+		})
+
+		Convey("JSON 中堆栈为数组", func() {
+			buf.Reset()
+			l.SetEncoder(JSONEncoder).Error("boom")
+			var got struct {
+				Stack []struct {
+					Func string `json:"func"`
+					File string `json:"file"`
+					Line int    `json:"line"`
+				} `json:"stack"`
+			}
+			So(json.Unmarshal(buf.Bytes(), &got), ShouldBeNil)
+			So(len(got.Stack), ShouldBeGreaterThan, 0)
+			So(got.Stack[0].File, ShouldEndWith, "logger_test.go")
+		})
+	})
+}
+
+func TestLogger_SetSampling(t *testing.T) {
+	Convey("采样只输出前 N 条和之后的每第 M 条，窗口结束时输出汇总", t, func() {
+		w := &gateWriter{gate: make(chan struct{})}
+		close(w.gate)
+		l := New().SetTimeFormat("").SetOutput(w)
+		l.SetSampling(ErrorLevel, SamplingOptions{Interval: 50 * time.Millisecond, First: 2, Thereafter: 3})
+
+		for i := 0; i < 10; i++ {
+			l.Error("db down")
+			l.Info("hello")
+		}
+		So(strings.Count(w.String(), "[ERRO] db down\n"), ShouldEqual, 4)
+		So(strings.Count(w.String(), "[INFO] hello\n"), ShouldEqual, 10)
+
+		time.Sleep(150 * time.Millisecond)
+		So(w.String(), ShouldEndWith, "[ERRO] suppressed 6 similar messages: db down\n")
+	})
+}
+
+type requestIDKey struct{}
+
+func TestLogger_Ctx(t *testing.T) {
+	Convey("Ctx 从 context 中提取已注册的值作为字段", t, func() {
+		RegisterContextKey("request_id", requestIDKey{})
+		l, buf := newBufferLogger()
+
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "r-1")
+		l.InfoCtx(ctx, "hello")
+		So(buf.String(), ShouldEqual, "[INFO] hello request_id=r-1\n")
+
+		So(l.Ctx(context.Background()), ShouldEqual, l)
+
+		Convey("Ctxf 与 Trace 函数同样附加字段且不改变 logger 的字段", func() {
+			buf.Reset()
+			l.SetLevel("trace")
+			tenant := l.With("tenant", "t1")
+			tenant.WarnCtxf(ctx, "slow %dms", 30)
+			tenant.TraceCtx(ctx, "enter")
+			tenant.TraceCtxf(ctx, "leave %s", "ok")
+			tenant.Info("plain")
+			So(buf.String(), ShouldEqual, "[WARN] slow 30ms tenant=t1 request_id=r-1\n"+
+				"[TRCE] enter tenant=t1 request_id=r-1\n"+
+				"[TRCE] leave ok tenant=t1 request_id=r-1\n"+
+				"[INFO] plain tenant=t1\n")
+		})
+
+		Convey("NewContext 和 FromContext 传递 logger", func() {
+			So(FromContext(NewContext(ctx, l)), ShouldEqual, l)
+			So(FromContext(context.Background()), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLogger_AddLevelOutput(t *testing.T) {
+	Convey("AddLevelOutput 只写入等级范围内的日志", t, func() {
+		l, all := newBufferLogger()
+		errs := &bytes.Buffer{}
+		l.AddLevelOutput(ErrorLevel, FatalLevel, errs)
+
+		l.Info("hello")
+		l.Error("boom")
+
+		So(all.String(), ShouldEqual, "[INFO] hello\n[ERRO] boom\n")
+		So(errs.String(), ShouldEqual, "[ERRO] boom\n")
+	})
+}
+
+func TestLogger_Fatal(t *testing.T) {
+	Convey("Fatal 依次执行退出钩子、刷新输出并调用退出函数", t, func() {
+		l, buf := newBufferLogger()
+		var calls []string
+		l.SetExitFunc(func(code int) {
+			calls = append(calls, fmt.Sprintf("exit %d", code))
+		}).RegisterExitHook(func() {
+			calls = append(calls, "hook")
+			l.Info("bye")
+		})
+
+		l.SetLevel("disable")
+		l.Fatal("boom")
+		So(calls, ShouldResemble, []string{"hook", "exit 1"})
+		So(buf.Len(), ShouldEqual, 0)
+	})
+}
+
+func TestLogger_Panic(t *testing.T) {
+	Convey("Panic 输出日志后以 exceptions.Exception 触发 panic", t, func() {
+		l, buf := newBufferLogger()
+
+		var recovered interface{}
+		func() {
+			defer func() { recovered = recover() }()
+			l.Panicf("bad %s", "state")
+		}()
+
+		So(buf.String(), ShouldEqual, "[PANC] bad state\n")
+		So(exceptions.IsException(recovered.(error)), ShouldBeTrue)
+		So(recovered.(exceptions.Exception).Message(), ShouldEqual, "bad state")
+	})
+}
+
+func TestLevel_Values(t *testing.T) {
+	Convey("等级的数值保持不变", t, func() {
+		So(DisableLevel, ShouldEqual, 0)
+		So(FatalLevel, ShouldEqual, 1)
+		So(PanicLevel, ShouldEqual, 2)
+		So(ErrorLevel, ShouldEqual, 3)
+		So(WarnLevel, ShouldEqual, 4)
+		So(InfoLevel, ShouldEqual, 5)
+		So(DebugLevel, ShouldEqual, 6)
+		So(TraceLevel, ShouldEqual, 7)
+	})
+}
+
+func TestLogger_RegisterLevel(t *testing.T) {
+	Convey("logger 自己注册的等级不影响全局 Levels", t, func() {
+		const noticeLevel = TraceLevel + 1
+		l, buf := newBufferLogger()
+		l.RegisterLevel(noticeLevel, &LevelMetadata{Name: "notice", RawText: "[NOTE]"})
+
+		child := l.Child("db")
+		So(child.SetLevel("notice").Level, ShouldEqual, noticeLevel)
+		child.Log(noticeLevel, "hello")
+		child.Trace("verbose")
+		So(buf.String(), ShouldEqual, "db: [NOTE] hello\ndb: [TRCE] verbose\n")
+
+		_, ok := Levels[noticeLevel]
+		So(ok, ShouldBeFalse)
+
+		Convey("未知的等级名返回错误", func() {
+			level, err := ParseLevelStrict("notice")
+			So(err, ShouldNotBeNil)
+			So(level, ShouldEqual, DisableLevel)
+
+			level, err = ParseLevelStrict("warning")
+			So(err, ShouldBeNil)
+			So(level, ShouldEqual, WarnLevel)
+
+			So(ParseLevel("notice"), ShouldEqual, DisableLevel)
+			So(ParseLevel("warning"), ShouldEqual, WarnLevel)
+			So(child.ParseLevel("notice"), ShouldEqual, noticeLevel)
+
+			So(l.SetLevel("nope").Level, ShouldEqual, InfoLevel)
+		})
+	})
+}
@@ -0,0 +1,227 @@
+// Package logtest provides helpers to test the code which logs through the `log.Logger`,
+// the logs are kept in memory instead of being written to the standard output.
+package logtest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tm-ad/g-base/log"
+)
+
+// DefaultRingSize is the number of the writes and of the records which are kept by `NewTestLogger`.
+const DefaultRingSize = 1000
+
+// RingBuffer is an `io.Writer` which keeps the last writes in memory,
+// each write is kept as a separate entry, i.e a log line.
+type RingBuffer struct {
+	mu      sync.Mutex
+	entries []string
+	next    int
+	full    bool
+}
+
+// NewRingBuffer returns a new `RingBuffer` which keeps the last "size" writes,
+// a non-positive "size" defaults to `DefaultRingSize`.
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &RingBuffer{entries: make([]string, size)}
+}
+
+// Write keeps a copy of the "p", the oldest entry is removed when the buffer is full.
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	b.entries[b.next] = string(p)
+	if b.next++; b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
+	}
+	b.mu.Unlock()
+
+	return len(p), nil
+}
+
+// Entries returns the kept writes from the oldest to the newest.
+func (b *RingBuffer) Entries() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.entries[:b.next]...)
+	}
+	entries := make([]string, 0, len(b.entries))
+	entries = append(entries, b.entries[b.next:]...)
+	return append(entries, b.entries[:b.next]...)
+}
+
+// String returns the kept writes joined together.
+func (b *RingBuffer) String() string {
+	return strings.Join(b.Entries(), "")
+}
+
+// Reset removes the kept writes.
+func (b *RingBuffer) Reset() {
+	b.mu.Lock()
+	for i := range b.entries {
+		b.entries[i] = ""
+	}
+	b.next = 0
+	b.full = false
+	b.mu.Unlock()
+}
+
+// TestLogger is a `log.Logger` which captures its logs, and the logs of
+// the loggers derived from it, for the assertions of a test.
+// The logs of every level are captured, only the last `DefaultRingSize` ones are kept.
+type TestLogger struct {
+	*log.Logger
+
+	t   testing.TB
+	buf *RingBuffer
+
+	mu      sync.Mutex
+	records []log.Log
+	next    int
+	full    bool
+	dumped  bool
+}
+
+// NewTestLogger returns a new `TestLogger` which reports to the "t",
+// its output is written to a `RingBuffer` without the time, see `Output`.
+//
+// The output is forwarded to the `t.Log` when an assertion fails,
+// defer the `DumpOnFailure` to forward it when the test fails for other reasons, i.e
+//
+// l := logtest.NewTestLogger(t)
+// defer l.DumpOnFailure()
+func NewTestLogger(t testing.TB) *TestLogger {
+	l := &TestLogger{
+		t:       t,
+		buf:     NewRingBuffer(DefaultRingSize),
+		records: make([]log.Log, DefaultRingSize),
+	}
+
+	l.Logger = log.New().SetLevel("trace").SetTimeFormat("").SetOutput(l.buf)
+	l.Logger.Handle(l.capture)
+	return l
+}
+
+func (l *TestLogger) capture(value *log.Log) bool {
+	// the value is released after the print, keep a copy.
+	record := *value
+	record.Fields = append([]log.Field(nil), value.Fields...)
+
+	l.mu.Lock()
+	l.records[l.next] = record
+	if l.next++; l.next == len(l.records) {
+		l.next = 0
+		l.full = true
+	}
+	l.mu.Unlock()
+	return false
+}
+
+// Records returns the kept logs from the oldest to the newest.
+func (l *TestLogger) Records() []log.Log {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]log.Log(nil), l.records[:l.next]...)
+	}
+	records := make([]log.Log, 0, len(l.records))
+	records = append(records, l.records[l.next:]...)
+	return append(records, l.records[:l.next]...)
+}
+
+// Output returns the ring buffer which holds the printed logs.
+func (l *TestLogger) Output() *RingBuffer {
+	return l.buf
+}
+
+// Reset removes the captured logs and the printed output.
+func (l *TestLogger) Reset() {
+	l.mu.Lock()
+	for i := range l.records {
+		l.records[i] = log.Log{}
+	}
+	l.next = 0
+	l.full = false
+	l.dumped = false
+	l.mu.Unlock()
+
+	l.buf.Reset()
+}
+
+// Logged reports whether a log of the "level" which message contains the "substring" was captured.
+func (l *TestLogger) Logged(level log.Level, substring string) bool {
+	for _, record := range l.Records() {
+		if record.Level == level && strings.Contains(record.Message, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertLogged fails the test if no log of the "level" contains the "substring",
+// see `Logged`. It reports whether the assertion passed.
+func (l *TestLogger) AssertLogged(level log.Level, substring string) bool {
+	l.t.Helper()
+
+	if l.Logged(level, substring) {
+		return true
+	}
+	l.t.Errorf("logtest: no %s log contains %q", l.levelName(level), substring)
+	l.dump()
+	return false
+}
+
+// AssertNotLogged fails the test if a log of the "level" contains the "substring",
+// see `Logged`. It reports whether the assertion passed.
+func (l *TestLogger) AssertNotLogged(level log.Level, substring string) bool {
+	l.t.Helper()
+
+	if !l.Logged(level, substring) {
+		return true
+	}
+	l.t.Errorf("logtest: a %s log contains %q", l.levelName(level), substring)
+	l.dump()
+	return false
+}
+
+// DumpOnFailure forwards the printed output to the `t.Log`
+// if the test has failed, it should be deferred after `NewTestLogger`.
+func (l *TestLogger) DumpOnFailure() {
+	l.t.Helper()
+
+	if l.t.Failed() {
+		l.dump()
+	}
+}
+
+// dump forwards the printed output to the `t.Log` once.
+func (l *TestLogger) dump() {
+	l.t.Helper()
+
+	l.mu.Lock()
+	dumped := l.dumped
+	l.dumped = true
+	l.mu.Unlock()
+	if dumped {
+		return
+	}
+
+	if out := l.buf.String(); out != "" {
+		l.t.Log("captured logs:\n" + out)
+	}
+}
+
+func (l *TestLogger) levelName(level log.Level) string {
+	if meta, ok := l.LevelMeta(level); ok {
+		return meta.Name
+	}
+	return "unknown"
+}
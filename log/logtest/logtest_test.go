@@ -0,0 +1,96 @@
+package logtest_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/tm-ad/g-base/log"
+	. "github.com/tm-ad/g-base/log/logtest"
+)
+
+// fakeT records the failures and the logs instead of failing the test.
+type fakeT struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Log(args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *fakeT) Failed() bool {
+	return len(t.errors) > 0
+}
+
+func TestRingBuffer(t *testing.T) {
+	Convey("RingBuffer 只保留最近的写入", t, func() {
+		b := NewRingBuffer(2)
+		b.Write([]byte("a\n"))
+		So(b.Entries(), ShouldResemble, []string{"a\n"})
+
+		b.Write([]byte("b\n"))
+		b.Write([]byte("c\n"))
+		So(b.Entries(), ShouldResemble, []string{"b\n", "c\n"})
+		So(b.String(), ShouldEqual, "b\nc\n")
+
+		b.Reset()
+		So(b.Entries(), ShouldBeEmpty)
+	})
+}
+
+func TestTestLogger(t *testing.T) {
+	Convey("TestLogger 捕获所有级别的日志", t, func() {
+		ft := &fakeT{}
+		l := NewTestLogger(ft)
+
+		l.With("user", 42).Trace("loading profile")
+		l.Child("db").Errorf("query failed: %s", "timeout")
+
+		records := l.Records()
+		So(records, ShouldHaveLength, 2)
+		So(records[0].Level, ShouldEqual, log.TraceLevel)
+		So(records[0].Fields, ShouldResemble, []log.Field{{Key: "user", Value: 42}})
+		So(records[1].Message, ShouldEqual, "query failed: timeout")
+		So(l.Output().String(), ShouldEqual, "[TRCE] loading profile user=42\ndb: [ERRO] query failed: timeout\n")
+
+		So(l.AssertLogged(log.ErrorLevel, "timeout"), ShouldBeTrue)
+		So(l.AssertNotLogged(log.WarnLevel, "timeout"), ShouldBeTrue)
+		So(ft.errors, ShouldBeEmpty)
+
+		Convey("断言失败时输出捕获的日志", func() {
+			So(l.AssertLogged(log.InfoLevel, "timeout"), ShouldBeFalse)
+			So(ft.errors, ShouldResemble, []string{`logtest: no info log contains "timeout"`})
+			So(ft.logs, ShouldHaveLength, 1)
+			So(ft.logs[0], ShouldContainSubstring, "[ERRO] query failed: timeout")
+
+			l.DumpOnFailure()
+			So(ft.logs, ShouldHaveLength, 1)
+		})
+
+		Convey("Reset 清空捕获的日志", func() {
+			l.Reset()
+			So(l.Records(), ShouldBeEmpty)
+			So(l.Output().Entries(), ShouldBeEmpty)
+			So(l.Logged(log.ErrorLevel, "timeout"), ShouldBeFalse)
+		})
+
+		Convey("只保留最近的 DefaultRingSize 条记录", func() {
+			for i := 0; i < DefaultRingSize+5; i++ {
+				l.Infof("request %d", i)
+			}
+
+			records := l.Records()
+			So(records, ShouldHaveLength, DefaultRingSize)
+			So(records[0].Message, ShouldEqual, "request 5")
+			So(records[DefaultRingSize-1].Message, ShouldEqual, fmt.Sprintf("request %d", DefaultRingSize+4))
+		})
+	})
+}
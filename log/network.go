@@ -0,0 +1,585 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The defaults of the `NetworkOptions`.
+const (
+	DefaultNetworkBatchSize     = 100
+	DefaultNetworkFlushInterval = time.Second
+	DefaultNetworkQueueSize     = 10000
+	DefaultNetworkMaxRetries    = 5
+	DefaultNetworkMinBackoff    = 100 * time.Millisecond
+	DefaultNetworkMaxBackoff    = 10 * time.Second
+	DefaultNetworkTimeout       = 10 * time.Second
+	DefaultNetworkMaxSpoolSize  = 64 << 20
+)
+
+// ErrWriterClosed is returned by the writes to a closed `NetworkWriter`.
+var ErrWriterClosed = errors.New("log: write to a closed writer")
+
+// spoolFrameHeader is the size of the length which prefixes each spooled record.
+const spoolFrameHeader = 4
+
+// NetworkOptions are the options of `NewNetworkWriter`.
+type NetworkOptions struct {
+	// URL is the address of the collector, "tcp://host:port" sends the logs
+	// as new line delimited records and "http://" or "https://" POSTs them.
+	URL string
+	// Encoder encodes the records, defaults to the `JSONEncoder`.
+	Encoder Encoder
+	// ContentType is the content type of the HTTP requests, defaults to "application/x-ndjson".
+	ContentType string
+	// Client is the client of the HTTP requests, defaults to a client with the `Timeout`.
+	Client *http.Client
+	// Timeout is the timeout of a single send, defaults to `DefaultNetworkTimeout`.
+	Timeout time.Duration
+
+	// BatchSize is the number of records which triggers a send, defaults to `DefaultNetworkBatchSize`.
+	BatchSize int
+	// FlushInterval is the max time a record waits to be sent, defaults to `DefaultNetworkFlushInterval`.
+	FlushInterval time.Duration
+	// QueueSize is the max number of the records that wait to be sent,
+	// the next ones are dropped, defaults to `DefaultNetworkQueueSize`.
+	QueueSize int
+
+	// MaxRetries is the number of the retries of a failed batch, defaults to `DefaultNetworkMaxRetries`,
+	// a negative value disables the retries.
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, it's doubled
+	// on each retry up to the `MaxBackoff`, defaults to `DefaultNetworkMinBackoff`.
+	MinBackoff time.Duration
+	// MaxBackoff is the max wait between two retries, defaults to `DefaultNetworkMaxBackoff`.
+	MaxBackoff time.Duration
+
+	// SpoolFile, if not empty, is the file which keeps the batches that
+	// couldn't be sent, they are replayed when the collector is back.
+	// Otherwise they are dropped.
+	SpoolFile string
+	// MaxSpoolSize is the max size in bytes of the spool file, the oldest
+	// records are dropped to make room for the new ones, defaults to `DefaultNetworkMaxSpoolSize`.
+	MaxSpoolSize int64
+}
+
+// NetworkWriter is an output which ships the records in batches to a collector over TCP or HTTP,
+// the writes never wait for the network, the batches are sent by a background goroutine.
+//
+// A failed batch is retried with an exponential backoff and then spooled to the `NetworkOptions#SpoolFile`,
+// the spooled records are sent before the new ones once the collector is reachable again.
+// The spooled records are sent at least once, the ones replayed just before a crash may be sent again.
+//
+// It should be added through `Logger#AddOutput`, the `Logger#Flush` waits until the
+// pending records are sent or spooled and the `Close` stops the background goroutine, i.e
+//
+// w, err := log.NewNetworkWriter(log.NetworkOptions{URL: "http://collector:8080/logs", SpoolFile: "./logs/spool"})
+// l.AddOutput(w)
+// defer w.Close()
+type NetworkWriter struct {
+	// dropped is the first field to keep it 64-bit aligned.
+	dropped uint64
+	opts    NetworkOptions
+	url     *url.URL
+
+	mu      sync.Mutex
+	pending [][]byte
+	closed  bool
+
+	// spoolOffset is the end of the replayed records and spoolSize
+	// the size of the spool file, only the background goroutine uses them.
+	spoolOffset int64
+	spoolSize   int64
+
+	conn    net.Conn
+	kick    chan struct{}
+	flushes chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+var _ encodedWriter = (*NetworkWriter)(nil)
+
+// NewNetworkWriter returns a new `NetworkWriter` which ships the records to the `NetworkOptions#URL`.
+func NewNetworkWriter(opts NetworkOptions) (*NetworkWriter, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "http", "https":
+	default:
+		return nil, fmt.Errorf("log: unsupported network url %q", opts.URL)
+	}
+
+	if opts.Encoder == nil {
+		opts.Encoder = JSONEncoder
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "application/x-ndjson"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultNetworkTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultNetworkBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultNetworkFlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultNetworkQueueSize
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultNetworkMaxRetries
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultNetworkMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = DefaultNetworkMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.MaxSpoolSize <= 0 {
+		opts.MaxSpoolSize = DefaultNetworkMaxSpoolSize
+	}
+	if opts.SpoolFile != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.SpoolFile), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &NetworkWriter{
+		opts:    opts,
+		url:     u,
+		kick:    make(chan struct{}, 1),
+		flushes: make(chan chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := w.loadSpool(); err != nil {
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// Encoder returns the encoder of the records, see `NetworkOptions#Encoder`.
+func (w *NetworkWriter) Encoder() Encoder {
+	return w.opts.Encoder
+}
+
+// Write queues the "p" record, a new line is added if it's missing.
+// The record is dropped when the queue is full, see `Dropped`,
+// and `ErrWriterClosed` is returned once the writer is closed.
+func (w *NetworkWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, ErrWriterClosed
+	}
+	if len(w.pending) >= w.opts.QueueSize {
+		w.mu.Unlock()
+		atomic.AddUint64(&w.dropped, 1)
+		return len(p), nil
+	}
+
+	record := make([]byte, len(p), len(p)+1)
+	copy(record, p)
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		record = append(record, '\n')
+	}
+	w.pending = append(w.pending, record)
+	full := len(w.pending) >= w.opts.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of the records that were dropped
+// because the queue was full or because they couldn't be sent nor spooled.
+func (w *NetworkWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Sync waits until the queued records are sent or spooled.
+func (w *NetworkWriter) Sync() error {
+	flushed := make(chan struct{})
+	select {
+	case w.flushes <- flushed:
+		<-flushed
+	case <-w.done:
+	}
+	return nil
+}
+
+// Close sends or spools the queued records and stops the background goroutine,
+// the next writes return `ErrWriterClosed`.
+func (w *NetworkWriter) Close() error {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
+
+func (w *NetworkWriter) run() {
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.kick:
+			w.flush()
+		case flushed := <-w.flushes:
+			w.flush()
+			close(flushed)
+		case <-w.stop:
+			w.flush()
+			if w.spoolOffset > 0 {
+				// don't replay again the sent records after a restart.
+				w.compactSpool(0)
+			}
+			if w.conn != nil {
+				w.conn.Close()
+			}
+			close(w.done)
+			return
+		}
+	}
+}
+
+// take returns the queued records and empties the queue.
+func (w *NetworkWriter) take() [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	records := w.pending
+	w.pending = nil
+	return records
+}
+
+// flush sends the spooled records and then the queued ones,
+// the queued ones are spooled if the collector is not reachable.
+func (w *NetworkWriter) flush() {
+	records := w.take()
+
+	if w.replay() != nil {
+		// the collector is down, don't wait for the retries.
+		w.spool(records)
+		return
+	}
+
+	if len(records) > 0 && w.sendWithRetry(bytes.Join(records, nil)) != nil {
+		w.spool(records)
+	}
+}
+
+// sendWithRetry sends the "batch" and retries with an exponential backoff,
+// the retries stop early when the writer is closed.
+func (w *NetworkWriter) sendWithRetry(batch []byte) error {
+	backoff := w.opts.MinBackoff
+	err := w.send(batch)
+	for retry := 0; err != nil && retry < w.opts.MaxRetries; retry++ {
+		select {
+		case <-time.After(backoff):
+		case <-w.stop:
+			return err
+		}
+
+		if backoff *= 2; backoff > w.opts.MaxBackoff {
+			backoff = w.opts.MaxBackoff
+		}
+		err = w.send(batch)
+	}
+	return err
+}
+
+func (w *NetworkWriter) send(batch []byte) error {
+	if w.url.Scheme == "tcp" {
+		return w.sendTCP(batch)
+	}
+	return w.sendHTTP(batch)
+}
+
+func (w *NetworkWriter) sendTCP(batch []byte) error {
+	if w.conn == nil {
+		conn, err := net.DialTimeout("tcp", w.url.Host, w.opts.Timeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(w.opts.Timeout))
+	if _, err := w.conn.Write(batch); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (w *NetworkWriter) sendHTTP(batch []byte) error {
+	resp, err := w.opts.Client.Post(w.url.String(), w.opts.ContentType, bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("log: collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// loadSpool reads the size of the spool file, a record which was partially
+// written when the process stopped is removed.
+func (w *NetworkWriter) loadSpool() error {
+	if w.opts.SpoolFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(w.opts.SpoolFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		n, err := w.skipFrame(r)
+		if err != nil {
+			break
+		}
+		w.spoolSize += n
+	}
+	fi, err := f.Stat()
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() > w.spoolSize {
+		return os.Truncate(w.opts.SpoolFile, w.spoolSize)
+	}
+	return nil
+}
+
+// frameSize reads the length which prefixes the next record of the spool file.
+func (w *NetworkWriter) frameSize(r *bufio.Reader) (int64, error) {
+	var header [spoolFrameHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[:]))
+	if size > w.opts.MaxSpoolSize {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return size, nil
+}
+
+// readFrame reads the next length prefixed record of the spool file.
+func (w *NetworkWriter) readFrame(r *bufio.Reader) ([]byte, error) {
+	size, err := w.frameSize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make([]byte, size)
+	if _, err := io.ReadFull(r, record); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return record, nil
+}
+
+// skipFrame skips the next record of the spool file and returns its size.
+func (w *NetworkWriter) skipFrame(r *bufio.Reader) (int64, error) {
+	size, err := w.frameSize(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if n, err := r.Discard(int(size)); int64(n) < size {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	return spoolFrameHeader + size, nil
+}
+
+// spool appends the "records" to the spool file, they are dropped if there isn't one.
+// The oldest records are dropped to keep the file under the `NetworkOptions#MaxSpoolSize`.
+func (w *NetworkWriter) spool(records [][]byte) {
+	if len(records) == 0 {
+		return
+	}
+	if w.opts.SpoolFile == "" {
+		atomic.AddUint64(&w.dropped, uint64(len(records)))
+		return
+	}
+
+	var frames []byte
+	for _, record := range records {
+		var header [spoolFrameHeader]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(record)))
+		frames = append(append(frames, header[:]...), record...)
+	}
+	// a batch bigger than the spool keeps its newest records.
+	for len(records) > 0 && int64(len(frames)) > w.opts.MaxSpoolSize {
+		frames = frames[spoolFrameHeader+len(records[0]):]
+		records = records[1:]
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	if excess := w.spoolSize + int64(len(frames)) - w.opts.MaxSpoolSize; excess > 0 {
+		if err := w.compactSpool(excess); err != nil {
+			atomic.AddUint64(&w.dropped, uint64(len(records)))
+			return
+		}
+	}
+
+	f, err := os.OpenFile(w.opts.SpoolFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write(frames)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		// the partially written record is removed by the next `loadSpool`.
+		atomic.AddUint64(&w.dropped, uint64(len(records)))
+		return
+	}
+	w.spoolSize += int64(len(frames))
+}
+
+// compactSpool rewrites the spool file without the replayed records and
+// drops the oldest records until at least "excess" bytes are released.
+func (w *NetworkWriter) compactSpool(excess int64) error {
+	f, err := os.Open(w.opts.SpoolFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(w.spoolOffset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	released, dropped := w.spoolOffset, uint64(0)
+	for released < excess {
+		n, err := w.skipFrame(r)
+		if err != nil {
+			break
+		}
+		released += n
+		dropped++
+	}
+
+	tmp := w.opts.SpoolFile + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.opts.SpoolFile)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	atomic.AddUint64(&w.dropped, dropped)
+	w.spoolOffset, w.spoolSize = 0, size
+	return nil
+}
+
+// replay sends the spooled records in batches of the `NetworkOptions#BatchSize`,
+// it starts from the records which weren't replayed yet and empties the spool file once they're all sent.
+func (w *NetworkWriter) replay() error {
+	if w.spoolOffset >= w.spoolSize {
+		return nil
+	}
+
+	f, err := os.Open(w.opts.SpoolFile)
+	if err != nil {
+		w.spoolOffset, w.spoolSize = 0, 0
+		return nil
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(w.spoolOffset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for w.spoolOffset < w.spoolSize {
+		var batch []byte
+		var size int64
+		for i := 0; i < w.opts.BatchSize; i++ {
+			record, err := w.readFrame(r)
+			if err != nil {
+				break
+			}
+			batch = append(batch, record...)
+			size += spoolFrameHeader + int64(len(record))
+		}
+		if size == 0 {
+			// the rest of the file is unreadable.
+			break
+		}
+
+		if err = w.send(batch); err != nil {
+			return err
+		}
+		w.spoolOffset += size
+	}
+
+	if err = os.Truncate(w.opts.SpoolFile, 0); err != nil {
+		return err
+	}
+	w.spoolOffset, w.spoolSize = 0, 0
+	return nil
+}
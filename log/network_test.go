@@ -0,0 +1,245 @@
+package log_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+// collector is an HTTP collector which keeps the received records,
+// it responds with 503 while it's down.
+type collector struct {
+	mu       sync.Mutex
+	down     bool
+	requests int
+	records  []string
+	bodies   []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	if c.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	c.bodies = append(c.bodies, string(body))
+	for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+		var record map[string]interface{}
+		json.Unmarshal([]byte(line), &record)
+		if message, ok := record["message"].(string); ok {
+			c.records = append(c.records, message)
+		}
+	}
+}
+
+func (c *collector) setDown(down bool) {
+	c.mu.Lock()
+	c.down = down
+	c.mu.Unlock()
+}
+
+func (c *collector) sent() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.bodies...)
+}
+
+func (c *collector) received() ([]string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.records...), c.requests
+}
+
+func TestNetworkWriter_HTTP(t *testing.T) {
+	Convey("NetworkWriter 批量 POST JSON 记录", t, func() {
+		c := &collector{}
+		server := httptest.NewServer(c)
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "network")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		spool := filepath.Join(dir, "spool")
+
+		w, err := NewNetworkWriter(NetworkOptions{
+			URL:           server.URL,
+			BatchSize:     10,
+			FlushInterval: time.Hour,
+			MaxRetries:    2,
+			MinBackoff:    time.Millisecond,
+			SpoolFile:     spool,
+		})
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		l := New().SetOutput(NopOutput).AddOutput(w)
+		l.Info("one")
+		l.Info("two")
+		So(l.Flush(), ShouldBeNil)
+
+		records, requests := c.received()
+		So(records, ShouldResemble, []string{"one", "two"})
+		So(requests, ShouldEqual, 1)
+
+		Convey("收集端不可用时重试后写入 spool 文件, 恢复后重放", func() {
+			c.setDown(true)
+			l.Info("three")
+			So(l.Flush(), ShouldBeNil)
+
+			_, requests = c.received()
+			So(requests, ShouldEqual, 1+3)
+			spooled, err := ioutil.ReadFile(spool)
+			So(err, ShouldBeNil)
+			So(string(spooled), ShouldContainSubstring, `"message":"three"`)
+
+			c.setDown(false)
+			l.Info("four")
+			So(l.Flush(), ShouldBeNil)
+
+			records, _ = c.received()
+			So(records, ShouldResemble, []string{"one", "two", "three", "four"})
+			spooled, err = ioutil.ReadFile(spool)
+			So(err, ShouldBeNil)
+			So(spooled, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestNetworkWriter_Spool(t *testing.T) {
+	Convey("NetworkWriter 的 spool 文件", t, func() {
+		c := &collector{down: true}
+		server := httptest.NewServer(c)
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "network")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		spool := filepath.Join(dir, "spool")
+
+		opts := NetworkOptions{
+			URL:           server.URL,
+			BatchSize:     2,
+			FlushInterval: time.Hour,
+			MaxRetries:    -1,
+			SpoolFile:     spool,
+		}
+
+		Convey("按记录而不是按行重放多行的记录", func() {
+			w, err := NewNetworkWriter(opts)
+			So(err, ShouldBeNil)
+			defer w.Close()
+
+			for _, record := range []string{"one\n  at main.go:1\n", "two\n  at main.go:2\n", "three\n  at main.go:3\n"} {
+				w.Write([]byte(record))
+			}
+			So(w.Sync(), ShouldBeNil)
+
+			c.setDown(false)
+			So(w.Sync(), ShouldBeNil)
+			So(c.sent(), ShouldResemble, []string{
+				"one\n  at main.go:1\ntwo\n  at main.go:2\n",
+				"three\n  at main.go:3\n",
+			})
+			So(w.Dropped(), ShouldEqual, 0)
+		})
+
+		Convey("超过 MaxSpoolSize 时丢弃最旧的记录", func() {
+			opts.MaxSpoolSize = 3 * (4 + 4)
+			w, err := NewNetworkWriter(opts)
+			So(err, ShouldBeNil)
+			defer w.Close()
+
+			for _, record := range []string{"r01", "r02", "r03", "r04", "r05"} {
+				w.Write([]byte(record))
+				So(w.Sync(), ShouldBeNil)
+			}
+			fi, err := os.Stat(spool)
+			So(err, ShouldBeNil)
+			So(fi.Size(), ShouldEqual, opts.MaxSpoolSize)
+			So(w.Dropped(), ShouldEqual, 2)
+
+			c.setDown(false)
+			So(w.Sync(), ShouldBeNil)
+			So(strings.Join(c.sent(), ""), ShouldEqual, "r03\nr04\nr05\n")
+		})
+
+		Convey("未重放的记录在重新打开后继续发送, 不完整的记录被丢弃", func() {
+			w, err := NewNetworkWriter(opts)
+			So(err, ShouldBeNil)
+			w.Write([]byte("one\n"))
+			So(w.Close(), ShouldBeNil)
+
+			f, err := os.OpenFile(spool, os.O_WRONLY|os.O_APPEND, 0644)
+			So(err, ShouldBeNil)
+			f.Write([]byte{0, 0, 0, 9, 't', 'w'})
+			f.Close()
+
+			c.setDown(false)
+			w, err = NewNetworkWriter(opts)
+			So(err, ShouldBeNil)
+			defer w.Close()
+			So(w.Sync(), ShouldBeNil)
+			So(c.sent(), ShouldResemble, []string{"one\n"})
+		})
+
+		Convey("关闭后的写入返回错误", func() {
+			w, err := NewNetworkWriter(opts)
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			n, err := w.Write([]byte("late\n"))
+			So(n, ShouldEqual, 0)
+			So(err, ShouldEqual, ErrWriterClosed)
+		})
+	})
+}
+
+func TestNetworkWriter_TCP(t *testing.T) {
+	Convey("NetworkWriter 通过 TCP 发送按行分隔的记录", t, func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer ln.Close()
+
+		w, err := NewNetworkWriter(NetworkOptions{URL: "tcp://" + ln.Addr().String(), Encoder: LogfmtEncoder, BatchSize: 2})
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		l := New().SetOutput(NopOutput).AddOutput(w)
+		l.Info("one")
+		l.Warn("two")
+
+		conn, err := ln.Accept()
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+
+		r := bufio.NewReader(conn)
+		for _, want := range []string{"level=info msg=one\n", "level=warn msg=two\n"} {
+			line, err := r.ReadString('\n')
+			So(err, ShouldBeNil)
+			So(line, ShouldEndWith, want)
+		}
+	})
+
+	Convey("不支持的 URL 返回错误", t, func() {
+		_, err := NewNetworkWriter(NetworkOptions{URL: "udp://127.0.0.1:514"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,211 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/tm-ad/g-base/util/pio"
+)
+
+// encodedWriter is implemented by outputs which
+// want their logs to be encoded with a specific `Encoder`.
+type encodedWriter interface {
+	io.Writer
+	Encoder() Encoder
+}
+
+type encodedOutput struct {
+	io.Writer
+	enc Encoder
+}
+
+func (o *encodedOutput) Encoder() Encoder {
+	return o.enc
+}
+
+// EncodedOutput wraps "w" in order to be passed to the `Logger#AddOutput`,
+// the logs written to "w" are encoded by "enc" instead of the Logger's encoder, i.e
+// colored text to the terminal and JSON to a rotate file:
+//
+// l.AddOutput(log.EncodedOutput(log.JSONEncoder, rotateWriter))
+func EncodedOutput(enc Encoder, w io.Writer) io.Writer {
+	return &encodedOutput{Writer: w, enc: enc}
+}
+
+// output is a printer of the dispatcher,
+// if "leveled" is true then it prints only the logs between "min" and "max".
+type output struct {
+	printer  *pio.Printer
+	leveled  bool
+	min, max Level
+}
+
+func (o *output) accepts(level Level) bool {
+	return !o.leveled || (level >= o.min && level <= o.max)
+}
+
+// dispatcher holds the outputs that have their own printer,
+// the asynchronous queue and the sampler, it's shared between a Logger
+// and the loggers derived from it until they change their outputs, see `fork`.
+type dispatcher struct {
+	*asyncDispatcher
+	sampler *sampler
+	mu      sync.RWMutex
+	outputs []*output
+	// writers are all the writers of the Logger, including
+	// the ones of its Printer, they are flushed by `Logger#Flush`.
+	writers []io.Writer
+	exit    *exitHandler
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{
+		asyncDispatcher: &asyncDispatcher{},
+		sampler:         newSampler(),
+		exit:            newExitHandler(),
+	}
+}
+
+// fork returns a new dispatcher which starts with the outputs of "d",
+// it shares the asynchronous queue, the sampler and the exit handler of "d".
+func (d *dispatcher) fork() *dispatcher {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return &dispatcher{
+		asyncDispatcher: d.asyncDispatcher,
+		sampler:         d.sampler,
+		outputs:         append([]*output(nil), d.outputs...),
+		writers:         append([]io.Writer(nil), d.writers...),
+		exit:            d.exit,
+	}
+}
+
+// add registers a new output which writes to "w",
+// if "w" is an `EncodedOutput` then it keeps its encoder,
+// otherwise the logs are encoded by the encoder of their Logger.
+func (d *dispatcher) add(w io.Writer, o *output) {
+	var enc Encoder
+	if ew, ok := w.(encodedWriter); ok {
+		enc = ew.Encoder()
+	}
+
+	o.printer = pio.NewPrinter("", nil).EnableDirectOutput().Hijack(encoderHijacker(enc)).Marshal(JSONMarshaler)
+	// SetOutput detects the terminal, NewPrinter treats any writer as one.
+	o.printer.SetOutput(w)
+
+	d.mu.Lock()
+	d.outputs = append(d.outputs, o)
+	d.mu.Unlock()
+
+	d.track(w)
+}
+
+// track registers the "writers" in order to be flushed,
+// the wrapped writers of `EncodedOutput` are unwrapped.
+func (d *dispatcher) track(writers ...io.Writer) {
+	d.mu.Lock()
+	for _, w := range writers {
+		if o, ok := w.(*encodedOutput); ok {
+			w = o.Writer
+		}
+		d.writers = append(d.writers, w)
+	}
+	d.mu.Unlock()
+}
+
+func (d *dispatcher) reset() {
+	d.mu.Lock()
+	d.outputs = nil
+	d.writers = nil
+	d.mu.Unlock()
+}
+
+// syncer is implemented by the writers which can
+// flush their data to the disk, i.e *os.File and *RotateWriter.
+type syncer interface {
+	Sync() error
+}
+
+// reopener is implemented by the writers which can reopen their files, i.e *RotateWriter.
+type reopener interface {
+	Reopen() error
+}
+
+// reopenOutputs reopens the tracked writers, it returns the first error.
+func (d *dispatcher) reopenOutputs() error {
+	d.mu.RLock()
+	writers := d.writers
+	d.mu.RUnlock()
+
+	var err error
+	for _, w := range writers {
+		if r, ok := w.(reopener); ok {
+			if rerr := r.Reopen(); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+	}
+	return err
+}
+
+// syncOutputs flushes the tracked writers,
+// the standard output and error are skipped.
+// It returns the first error.
+func (d *dispatcher) syncOutputs() error {
+	d.mu.RLock()
+	writers := d.writers
+	d.mu.RUnlock()
+
+	var err error
+	for _, w := range writers {
+		if w == os.Stdout || w == os.Stderr {
+			continue
+		}
+		if s, ok := w.(syncer); ok {
+			if serr := s.Sync(); serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}
+	return err
+}
+
+func (d *dispatcher) print(log *Log) {
+	d.mu.RLock()
+	for _, o := range d.outputs {
+		if o.accepts(log.Level) {
+			o.printer.Print(log)
+		}
+	}
+	d.mu.RUnlock()
+}
+
+// AddLevelOutput adds one or more `io.Writer` which receive only the logs
+// with a level between "minLevel" and "maxLevel", both inclusive and in any order, i.e
+// `AddLevelOutput(FatalLevel, ErrorLevel, errorFile)` writes the errors and the fatal logs to the "errorFile".
+//
+// Each writer gets a printer of its own, the writers that are wrapped
+// by `EncodedOutput` keep their encoder.
+//
+// Returns itself.
+func (l *Logger) AddLevelOutput(minLevel, maxLevel Level, writers ...io.Writer) *Logger {
+	l.ownOutputs()
+	if minLevel > maxLevel {
+		minLevel, maxLevel = maxLevel, minLevel
+	}
+
+	for _, w := range writers {
+		l.dispatcher.add(w, &output{leveled: true, min: minLevel, max: maxLevel})
+	}
+	return l
+}
+
+// Reopen reopens the outputs of "l" which support it, i.e the `RotateWriter`s,
+// after their files were moved by an external tool like logrotate.
+//
+// It returns the first error of the outputs.
+func (l *Logger) Reopen() error {
+	return l.dispatcher.reopenOutputs()
+}
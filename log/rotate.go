@@ -1,6 +1,7 @@
 package log
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"github.com/tm-ad/g-base/util/fs"
@@ -11,6 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,13 +21,20 @@ import (
 // region rotate option
 
 const (
-	optkeyMaxAge       = "max-age"
-	optkeyRotationTime = "rotation-time"
+	optkeyMaxAge        = "max-age"
+	optkeyRotationTime  = "rotation-time"
+	optkeyRotationSize  = "rotation-size"
+	optkeyRotationCount = "rotation-count"
+	optkeyCompress      = "compress"
+	optkeyLinkName      = "link-name"
+	optkeyHandler       = "handler"
+	optkeyClock         = "clock"
+	optkeyForceNewFile  = "force-new-file"
 )
 
 // WithMaxAge creates a new Option that sets the
 // max age of a log file before it gets purged from
-// the file system.
+// the file system, zero disables the age purge.
 func WithMaxAge(d time.Duration) Option {
 	return option.New(optkeyMaxAge, d)
 }
@@ -36,6 +45,69 @@ func WithRotationTime(d time.Duration) Option {
 	return option.New(optkeyRotationTime, d)
 }
 
+// WithRotationSize creates a new Option that sets the
+// size in bytes of a log file before it gets rotated
+// to the next generation file, i.e "foo.log.1".
+// It works together with the time rotation.
+func WithRotationSize(s int64) Option {
+	return option.New(optkeyRotationSize, s)
+}
+
+// WithRotationCount creates a new Option that sets the
+// number of the rotated files, besides the current one,
+// which are kept, the older ones are purged from the file system.
+// It works together with the max age, zero disables it.
+// The files which other processes still write to are not purged.
+func WithRotationCount(n uint) Option {
+	return option.New(optkeyRotationCount, n)
+}
+
+// WithCompress creates a new Option that makes the
+// previous log file to be compressed to "foo.log.gz"
+// in the background after each rotation. When several
+// processes write to the same files, the last one which
+// rotates away from a file compresses it.
+func WithCompress(b bool) Option {
+	return option.New(optkeyCompress, b)
+}
+
+// WithLinkName creates a new Option that sets the
+// symbolic link name that gets linked to the current
+// file name being used, i.e "app.log".
+func WithLinkName(s string) Option {
+	return option.New(optkeyLinkName, s)
+}
+
+// WithHandler creates a new Option that specifies the
+// RotateHandler object that gets invoked when an event occurs,
+// i.e `FileRotatedEvent` and `FilesPurgedEvent`.
+func WithHandler(h RotateHandler) Option {
+	return option.New(optkeyHandler, h)
+}
+
+// WithClock creates a new Option that sets a clock
+// that the RotateWriter object will use to determine
+// the current time, defaults to `Local`.
+func WithClock(c Clock) Option {
+	return option.New(optkeyClock, c)
+}
+
+// WithLocation creates a new Option that sets up a
+// "Clock" interface that the RotateWriter object will use
+// to determine the current time, in the given location.
+func WithLocation(loc *time.Location) Option {
+	return option.New(optkeyClock, clockFn(func() time.Time {
+		return time.Now().In(loc)
+	}))
+}
+
+// ForceNewFile creates a new Option that ensures a new file
+// is created by the first write of a RotateWriter, if the file
+// already exists then a generation file, i.e "foo.log.1", is created.
+func ForceNewFile() Option {
+	return option.New(optkeyForceNewFile, true)
+}
+
 func defaultRotatePattern(pattern string) string {
 	if pattern == "" {
 		return "%Y-%m-%d"
@@ -102,28 +174,138 @@ func (c clockFn) Now() time.Time {
 // returns the current time in the local timezone
 var Local = clockFn(time.Now)
 
+// RotateEventType is the type of a RotateEvent.
+type RotateEventType int
+
+// The types of the events which are passed to the RotateHandler.
+const (
+	InvalidEventType RotateEventType = iota
+	FileRotatedEventType
+	FilesPurgedEventType
+	FileCompressedEventType
+)
+
+// RotateEvent is an event of a RotateWriter.
+type RotateEvent interface {
+	Type() RotateEventType
+}
+
+// RotateHandler is the interface of the objects which receive
+// the events of a RotateWriter, see `WithHandler`.
+// The events are delivered on separate goroutines.
+type RotateHandler interface {
+	Handle(RotateEvent)
+}
+
+// RotateHandlerFunc is an adapter which allows
+// a function to be used as a RotateHandler.
+type RotateHandlerFunc func(RotateEvent)
+
+// Handle calls h(e).
+func (h RotateHandlerFunc) Handle(e RotateEvent) {
+	h(e)
+}
+
+// FileRotatedEvent is sent when the RotateWriter switches to a new file.
+type FileRotatedEvent struct {
+	prev    string
+	current string
+}
+
+// Type returns the FileRotatedEventType.
+func (e *FileRotatedEvent) Type() RotateEventType {
+	return FileRotatedEventType
+}
+
+// PreviousFile returns the file which was used before the rotation,
+// it's empty for the first file of the RotateWriter.
+func (e *FileRotatedEvent) PreviousFile() string {
+	return e.prev
+}
+
+// CurrentFile returns the file which is used after the rotation.
+func (e *FileRotatedEvent) CurrentFile() string {
+	return e.current
+}
+
+// FilesPurgedEvent is sent when the old files are removed,
+// see `WithMaxAge` and `WithRotationCount`.
+type FilesPurgedEvent struct {
+	files []string
+}
+
+// Type returns the FilesPurgedEventType.
+func (e *FilesPurgedEvent) Type() RotateEventType {
+	return FilesPurgedEventType
+}
+
+// Files returns the removed files.
+func (e *FilesPurgedEvent) Files() []string {
+	return e.files
+}
+
+// FileCompressedEvent is sent when a previous file
+// has been compressed and removed, see `WithCompress`.
+type FileCompressedEvent struct {
+	file       string
+	compressed string
+}
+
+// Type returns the FileCompressedEventType.
+func (e *FileCompressedEvent) Type() RotateEventType {
+	return FileCompressedEventType
+}
+
+// File returns the removed file.
+func (e *FileCompressedEvent) File() string {
+	return e.file
+}
+
+// CompressedFile returns the ".gz" file.
+func (e *FileCompressedEvent) CompressedFile() string {
+	return e.compressed
+}
+
 var patternConversionRegexps = []*regexp.Regexp{
 	regexp.MustCompile(`%[%+A-Za-z]`),
 	regexp.MustCompile(`\*+`),
 }
 
-type cleanupGuard struct {
-	enable bool
-	fn     func()
-	mutex  sync.Mutex
+// digitGlobs are the glob patterns of the strftime verbs which are formatted as
+// a fixed number of digits, the other verbs match anything. They keep the glob
+// patterns of the files of different names apart, i.e "app%Y.log" doesn't
+// match the "app.error2019.log" of the "app.error%Y.log" pattern.
+var digitGlobs = map[byte]string{
+	'Y': "[0-9][0-9][0-9][0-9]",
+	'F': "[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]",
+	'j': "[0-9][0-9][0-9]",
+	'C': "[0-9][0-9]",
+	'y': "[0-9][0-9]",
+	'm': "[0-9][0-9]",
+	'd': "[0-9][0-9]",
+	'H': "[0-9][0-9]",
+	'I': "[0-9][0-9]",
+	'M': "[0-9][0-9]",
+	'S': "[0-9][0-9]",
 }
 
-func (g *cleanupGuard) Enable() {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-	g.enable = true
-}
-func (g *cleanupGuard) Run() {
-	g.fn()
+// globPattern converts the strftime "pattern" to the glob pattern of its files.
+func globPattern(pattern string) string {
+	glob := patternConversionRegexps[0].ReplaceAllStringFunc(pattern, func(verb string) string {
+		if g, ok := digitGlobs[verb[1]]; ok {
+			return g
+		}
+		return "*"
+	})
+	return patternConversionRegexps[1].ReplaceAllString(glob, "*")
 }
 
+// errLocked is returned by lockFile when the lock is held by another process.
+var errLocked = errors.New("locked by another process")
+
 // NewRotateFileLog 创建一个根据时间周期切分的文件日志
-// 	root: 日志存储的根路径，不能为空
+//
+//	root: 日志存储的根路径，不能为空
 //	name: 日志的主文件名，可认为为 prefix
 //	lvl: 日志的记录的等级
 //	pattern: 文件名的格式化字符串，如 %Y-%m-%d
@@ -133,51 +315,90 @@ func NewRotateFileLog(root, name, lvl, pattern string, rotationTime, maxAge time
 	l := New()
 	l.SetLevel(defaultLevel(lvl))
 
-	// 检查并创建日志根目录
-	if err := fs.Mkdir(root); err != nil {
-		TipInDevelopment(fmt.Sprintf(`log root initialize failed: %v \n`, err))
+	writer, err := newRotateFile(root, defaultName(name), pattern, rotationTime, maxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	l.AddOutput(writer)
+
+	return l, nil
+}
+
+// NewSplitRotateFileLog 创建一个根据时间周期切分的文件日志，所有日志写入 name 文件，
+// error 及更严重等级的日志同时写入 errName 文件，参数同 NewRotateFileLog
+//
+//	errName: 错误日志的主文件名，为空时为 error
+func NewSplitRotateFileLog(root, name, errName, lvl, pattern string, rotationTime, maxAge time.Duration) (*Logger, error) {
+	writer, err := newRotateFile(root, defaultName(name), pattern, rotationTime, maxAge)
+	if err != nil {
+		return nil, err
 	}
-	baseLogName := path.Join(root, defaultName(name))
-	// 构建 rotate log file
-	writer, err := NewRotateWriter(
-		baseLogName+defaultRotatePattern(pattern)+".log",
-		WithMaxAge(defaultMaxAge(maxAge)),
-		WithRotationTime(defaultRotationTime(rotationTime)),
-	)
 
+	if errName == "" {
+		errName = "error"
+	}
+	errWriter, err := newRotateFile(root, errName, pattern, rotationTime, maxAge)
 	if err != nil {
+		writer.Close()
 		return nil, err
 	}
 
+	l := New()
+	l.SetLevel(defaultLevel(lvl))
 	l.AddOutput(writer)
+	l.AddLevelOutput(FatalLevel, ErrorLevel, errWriter)
 
 	return l, nil
 }
 
+// newRotateFile 在 root 下创建名为 name 的 RotateWriter
+func newRotateFile(root, name, pattern string, rotationTime, maxAge time.Duration) (*RotateWriter, error) {
+	// 检查并创建日志根目录
+	if err := fs.Mkdir(root); err != nil {
+		TipInDevelopment(fmt.Sprintf(`log root initialize failed: %v \n`, err))
+	}
+	baseLogName := path.Join(root, name)
+	// 构建 rotate log file
+	return NewRotateWriter(
+		baseLogName+defaultRotatePattern(pattern)+".log",
+		WithMaxAge(defaultMaxAge(maxAge)),
+		WithRotationTime(defaultRotationTime(rotationTime)),
+	)
+}
+
 // RotateWriter represents a log file that gets
 // automatically rotated as you write to it.
+//
+// Several processes can write to the same files, the files are opened
+// with O_APPEND and each Write is a single write to the file, so the
+// records of the Logger are not interleaved. The purge and the compression
+// of the old files are coordinated by advisory locks, see `WithMaxAge` and `WithCompress`.
 type RotateWriter struct {
-	clock        Clock
-	curFn        string
-	curBaseFn    string
-	globPattern  string
-	generation   int
-	maxAge       time.Duration
-	mutex        sync.RWMutex
-	outFh        *os.File
-	pattern      *strftime.Strftime
-	rotationTime time.Duration
-	forceNewFile bool
+	clock         Clock
+	curFn         string
+	curBaseFn     string
+	globPattern   string
+	generation    int
+	maxAge        time.Duration
+	mutex         sync.RWMutex
+	outFh         *os.File
+	pattern       *strftime.Strftime
+	rotationTime  time.Duration
+	rotationSize  int64
+	rotationCount uint
+	compress      bool
+	compressing   sync.WaitGroup
+	inflightMu    sync.Mutex
+	inflight      map[string]bool
+	linkName      string
+	eventHandler  RotateHandler
+	forceNewFile  bool
 }
 
 // NewRotateWriter creates a new RotateLogs object. A log filename pattern
 // must be passed. Optional `Option` parameters may be passed
 func NewRotateWriter(p string, options ...Option) (*RotateWriter, error) {
-	globPattern := p
-	for _, re := range patternConversionRegexps {
-		globPattern = re.ReplaceAllString(globPattern, "*")
-	}
-
 	pattern, err := strftime.New(p)
 	if err != nil {
 		return nil, errors.New(`invalid strftime pattern`)
@@ -185,6 +406,11 @@ func NewRotateWriter(p string, options ...Option) (*RotateWriter, error) {
 
 	var clock Clock = Local
 	rotationTime := 24 * time.Hour
+	var rotationSize int64
+	var rotationCount uint
+	var compress bool
+	var linkName string
+	var eventHandler RotateHandler
 	var maxAge time.Duration
 	var forceNewFile bool
 
@@ -200,22 +426,45 @@ func NewRotateWriter(p string, options ...Option) (*RotateWriter, error) {
 			if rotationTime < 0 {
 				rotationTime = 0
 			}
+		case optkeyRotationSize:
+			rotationSize = o.Value().(int64)
+			if rotationSize < 0 {
+				rotationSize = 0
+			}
+		case optkeyRotationCount:
+			rotationCount = o.Value().(uint)
+		case optkeyCompress:
+			compress = o.Value().(bool)
+		case optkeyLinkName:
+			linkName = o.Value().(string)
+		case optkeyHandler:
+			eventHandler = o.Value().(RotateHandler)
+		case optkeyClock:
+			clock = o.Value().(Clock)
+		case optkeyForceNewFile:
+			forceNewFile = o.Value().(bool)
 		}
 	}
 
 	return &RotateWriter{
-		clock:        clock,
-		globPattern:  globPattern,
-		maxAge:       maxAge,
-		pattern:      pattern,
-		rotationTime: rotationTime,
-		forceNewFile: forceNewFile,
+		clock:         clock,
+		globPattern:   globPattern(p),
+		maxAge:        maxAge,
+		pattern:       pattern,
+		rotationTime:  rotationTime,
+		rotationSize:  rotationSize,
+		rotationCount: rotationCount,
+		compress:      compress,
+		inflight:      make(map[string]bool),
+		linkName:      linkName,
+		eventHandler:  eventHandler,
+		forceNewFile:  forceNewFile,
 	}, nil
 }
 
 // Write satisfies the io.Writer interface. It writes to the
 // appropriate file handle that is currently being used.
-// If we have reached rotation time, the target file gets
+// If we have reached rotation time or rotation size, the target file gets
 // automatically rotated, and also purged if necessary.
 func (rl *RotateWriter) Write(p []byte) (n int, err error) {
 	// Guard against concurrent writes
@@ -247,7 +496,7 @@ func (rl *RotateWriter) genFilename() string {
 	if now.Location() != time.UTC {
 		base = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
 		base = base.Truncate(time.Duration(rl.rotationTime))
-		base = time.Date(base.Year(), base.Month(), base.Day(), base.Hour(), base.Minute(), base.Second(), base.Nanosecond(), base.Location())
+		base = time.Date(base.Year(), base.Month(), base.Day(), base.Hour(), base.Minute(), base.Second(), base.Nanosecond(), now.Location())
 	} else {
 		base = now.Truncate(time.Duration(rl.rotationTime))
 	}
@@ -257,12 +506,12 @@ func (rl *RotateWriter) genFilename() string {
 // must be locked during this operation
 func (rl *RotateWriter) getWriter_nolock(bailOnRotateFail, useGenerationalNames bool) (io.Writer, error) {
 	generation := rl.generation
-	// previousFn := rl.curFn
+	previousFn := rl.curFn
 	// This filename contains the name of the "NEW" filename
 	// to log to, which may be newer than rl.currentFilename
 	baseFn := rl.genFilename()
 	filename := baseFn
-	var forceNewFile bool
+	var forceNewFile, sizeRotation bool
 	if baseFn != rl.curBaseFn {
 		generation = 0
 		// even though this is the first write after calling New(),
@@ -271,28 +520,16 @@ func (rl *RotateWriter) getWriter_nolock(bailOnRotateFail, useGenerationalNames
 			forceNewFile = true
 		}
 	} else {
-		if !useGenerationalNames {
-			// nothing to do
-			return rl.outFh, nil
-		}
-		forceNewFile = true
-		generation++
-	}
-	if forceNewFile {
-		// A new file has been requested. Instead of just using the
-		// regular strftime pattern, we create a new file name using
-		// generational names such as "foo.1", "foo.2", "foo.3", etc
-		var name string
-		for {
-			if generation == 0 {
-				name = filename
-			} else {
-				name = fmt.Sprintf("%s.%d", filename, generation)
-			}
-			if _, err := os.Stat(name); err != nil {
-				filename = name
-				break
+		sizeRotation = !useGenerationalNames && rl.sizeExceeded_nolock()
+		if !useGenerationalNames && !sizeRotation {
+			if rl.outFh != nil {
+				// nothing to do
+				return rl.outFh, nil
 			}
+			// the file was closed, open it again
+			filename = rl.curFn
+		} else {
+			forceNewFile = true
 			generation++
 		}
 	}
@@ -302,15 +539,61 @@ func (rl *RotateWriter) getWriter_nolock(bailOnRotateFail, useGenerationalNames
 	if err := os.MkdirAll(dirname, 0755); err != nil {
 		return nil, errors.New(fmt.Sprintf("failed to create directory %s", dirname))
 	}
-	// if we got here, then we need to create a file
-	fh, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("failed to open file %s: %s", rl.pattern, err))
+
+	// a forced new file doesn't join the existing generations
+	join := sizeRotation || !forceNewFile
+	var fh *os.File
+	for {
+		if forceNewFile {
+			// A new file has been requested. Instead of just using the
+			// regular strftime pattern, we create a new file name using
+			// generational names such as "foo.1", "foo.2", "foo.3", etc
+			for {
+				name := filename
+				if generation > 0 {
+					name = fmt.Sprintf("%s.%d", filename, generation)
+				}
+				// another process may have rotated by size already, join its file
+				if !fileExists(name+".gz") && (!fileExists(name) || join && name != rl.curFn) {
+					filename = name
+					break
+				}
+				generation++
+			}
+		}
+
+		// if we got here, then we need to create a file
+		var err error
+		fh, err = openLogFile(filename)
+		if err == nil && join && rl.sizeExceeded(fh) {
+			fh.Close()
+			err = errLocked
+		}
+		if err == nil {
+			break
+		}
+		if err != errLocked {
+			return nil, errors.New(fmt.Sprintf("failed to open file %s: %s", rl.pattern, err))
+		}
+		// the file is full or another process compresses it, go to the next generation
+		filename = baseFn
+		forceNewFile = true
+		generation++
+	}
+
+	var compressFn string
+	if rl.compress && previousFn != "" && previousFn != filename {
+		// mark it before the purge, which skips the files that are compressed
+		compressFn = previousFn
+		rl.setInflight(compressFn, true)
 	}
 
 	if err := rl.rotate_nolock(filename); err != nil {
 		err = errors.New("failed to rotate")
 		if bailOnRotateFail {
+			if compressFn != "" {
+				rl.setInflight(compressFn, false)
+			}
 			// Failure to rotate is a problem, but it's really not a great
 			// idea to stop your application just because you couldn't rename
 			// your log.
@@ -332,97 +615,372 @@ func (rl *RotateWriter) getWriter_nolock(bailOnRotateFail, useGenerationalNames
 	rl.curFn = filename
 	rl.generation = generation
 
-	//if h := rl.eventHandler; h != nil {
-	//	go h.Handle(&FileRotatedEvent{
-	//		prev:    previousFn,
-	//		current: filename,
-	//	})
-	//}
+	if compressFn != "" {
+		// compress on a separate goroutine, the writes go to the new file
+		rl.compressing.Add(1)
+		go rl.compressFile(compressFn)
+	}
+
+	if h := rl.eventHandler; h != nil && previousFn != filename {
+		go h.Handle(&FileRotatedEvent{
+			prev:    previousFn,
+			current: filename,
+		})
+	}
+	return fh, nil
+}
+
+// sizeExceeded_nolock reports whether the current file has reached the rotation size.
+func (rl *RotateWriter) sizeExceeded_nolock() bool {
+	return rl.outFh != nil && rl.sizeExceeded(rl.outFh)
+}
+
+// sizeExceeded reports whether the "fh" file has reached the rotation size,
+// the size is read from the file because other processes may append to it.
+func (rl *RotateWriter) sizeExceeded(fh *os.File) bool {
+	if rl.rotationSize <= 0 {
+		return false
+	}
+
+	fi, err := fh.Stat()
+	return err == nil && fi.Size() >= rl.rotationSize
+}
+
+// openLogFile opens the "name" file to append to it, the file is shared with the
+// other processes which write to it and it's not compressed until they all rotate away.
+// It returns errLocked when another process compresses the file or has compressed it.
+func openLogFile(name string) (*os.File, error) {
+	if fileExists(name + ".gz") {
+		return nil, errLocked
+	}
+
+	fh, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if !tryLockShared(fh) {
+		fh.Close()
+		return nil, errLocked
+	}
+
+	// the compression removes the file, make sure we didn't open it meanwhile
+	fi, err := fh.Stat()
+	if err == nil {
+		var current os.FileInfo
+		current, err = os.Stat(name)
+		if err == nil && !os.SameFile(fi, current) {
+			err = errLocked
+		} else if err == nil && fileExists(name+".gz") {
+			// it was compressed before we created it again, don't leave it empty
+			if fi.Size() == 0 {
+				os.Remove(name)
+			}
+			err = errLocked
+		}
+	}
+	if os.IsNotExist(err) {
+		err = errLocked
+	}
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
 	return fh, nil
 }
 
 func (rl *RotateWriter) rotate_nolock(filename string) error {
-	lockfn := filename + `_lock`
-	fh, err := os.OpenFile(lockfn, os.O_CREATE|os.O_EXCL, 0644)
+	// the lock is shared by the processes which write to the same files
+	unlock, err := lockFile(filename + `_lock`)
 	if err != nil {
-		// Can't lock, just return
+		if err == errLocked {
+			// another process is rotating, let it purge
+			return nil
+		}
 		return err
 	}
+	defer unlock()
 
-	var guard cleanupGuard
-	guard.fn = func() {
-		fh.Close()
-		os.Remove(lockfn)
+	if rl.linkName != "" {
+		// a broken link should not stop the purge
+		if err := link(filename, rl.linkName); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
 	}
-	defer guard.Run()
 
-	//if rl.linkName != "" {
-	//	tmpLinkName := filename + `_symlink`
-	//	if err := os.Symlink(filename, tmpLinkName); err != nil {
-	//		return errors.Wrap(err, `failed to create new symlink`)
-	//	}
-	//
-	//	if err := os.Rename(tmpLinkName, rl.linkName); err != nil {
-	//		return errors.Wrap(err, `failed to rename new symlink`)
-	//	}
-	//}
-	//
-	//if rl.maxAge <= 0 && rl.rotationCount <= 0 {
-	//	return errors.New("panic: maxAge and rotationCount are both set")
-	//}
+	if rl.maxAge <= 0 && rl.rotationCount <= 0 {
+		return nil
+	}
 
-	matches, err := filepath.Glob(rl.globPattern)
+	// taken before the glob, a file which is compressed meanwhile is still skipped
+	inflight := rl.inflightFiles()
+	files, err := rl.rotatedFiles(filename)
 	if err != nil {
 		return err
 	}
 
 	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
 	var toUnlink []string
-	for _, path := range matches {
-		// Ignore lock files
-		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
+	var locked []*os.File
+	for i, f := range files {
+		if inflight[f.path] {
+			// it's removed once compressed, the next purge counts its ".gz"
+			continue
+		}
+		overCount := rl.rotationCount > 0 && uint(i) >= rl.rotationCount
+		expired := rl.maxAge > 0 && f.modTime.Before(cutoff)
+		if !overCount && !expired {
 			continue
 		}
 
-		fi, err := os.Stat(path)
+		// the writers of the other processes hold a shared lock on their current file,
+		// it's kept locked until it's removed so that they don't open it meanwhile
+		fh, err := os.Open(f.path)
 		if err != nil {
 			continue
 		}
-		//_, err := os.Lstat(path)
-		//if err != nil {
-		//	continue
-		//}
-
-		if rl.maxAge > 0 && fi.ModTime().After(cutoff) {
+		if !tryLockExclusive(fh) {
+			fh.Close()
 			continue
 		}
-
-		//if rl.rotationCount > 0 && fl.Mode()&os.ModeSymlink == os.ModeSymlink {
-		//	continue
-		//}
-		toUnlink = append(toUnlink, path)
+		toUnlink = append(toUnlink, f.path)
+		locked = append(locked, fh)
 	}
 
-	//if rl.rotationCount > 0 {
-	//	// Only delete if we have more than rotationCount
-	//	if rl.rotationCount >= uint(len(toUnlink)) {
-	//		return nil
-	//	}
-	//
-	//	toUnlink = toUnlink[:len(toUnlink)-int(rl.rotationCount)]
-	//}
-
 	if len(toUnlink) <= 0 {
 		return nil
 	}
 
-	guard.Enable()
 	go func() {
 		// unlink files on a separate goroutine
-		for _, path := range toUnlink {
-			os.Remove(path)
+		for i, path := range toUnlink {
+			removeLockedFile(locked[i], path)
+		}
+
+		if h := rl.eventHandler; h != nil {
+			h.Handle(&FilesPurgedEvent{files: toUnlink})
 		}
 	}()
 
 	return nil
 }
+
+// link atomically points the "linkName" symbolic link to the "filename",
+// through a temporary link which replaces it. The target is relative to
+// the directory of the link when possible.
+func link(filename, linkName string) error {
+	target := filename
+	if rel, err := filepath.Rel(filepath.Dir(linkName), filename); err == nil {
+		target = rel
+	}
+
+	tmpLinkName := filename + `_symlink`
+	// a previous crash may have left it behind
+	os.Remove(tmpLinkName)
+	if err := os.Symlink(target, tmpLinkName); err != nil {
+		return fmt.Errorf("failed to create new symlink: %v", err)
+	}
+
+	if err := os.Rename(tmpLinkName, linkName); err != nil {
+		os.Remove(tmpLinkName)
+		return fmt.Errorf("failed to rename new symlink: %v", err)
+	}
+	return nil
+}
+
+// compressFile compresses the "filename" to "filename.gz" and removes it,
+// the compressed file matches the glob pattern of the purge.
+func (rl *RotateWriter) compressFile(filename string) {
+	defer rl.compressing.Done()
+
+	err := gzipFile(filename)
+	rl.setInflight(filename, false)
+	if err == errLocked {
+		// another process writes to it or has compressed it
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to compress %s: %s\n", filename, err)
+		return
+	}
+
+	if h := rl.eventHandler; h != nil {
+		h.Handle(&FileCompressedEvent{
+			file:       filename,
+			compressed: filename + ".gz",
+		})
+	}
+}
+
+// setInflight marks the "filename" as being compressed, or unmarks it.
+func (rl *RotateWriter) setInflight(filename string, compressing bool) {
+	rl.inflightMu.Lock()
+	if compressing {
+		rl.inflight[filename] = true
+	} else {
+		delete(rl.inflight, filename)
+	}
+	rl.inflightMu.Unlock()
+}
+
+// inflightFiles returns a copy of the files which are being compressed.
+func (rl *RotateWriter) inflightFiles() map[string]bool {
+	rl.inflightMu.Lock()
+	defer rl.inflightMu.Unlock()
+
+	files := make(map[string]bool, len(rl.inflight))
+	for filename := range rl.inflight {
+		files[filename] = true
+	}
+	return files
+}
+
+// gzipFile compresses the "filename" to "filename.gz" through a temporary file and removes it.
+// It returns errLocked when another process writes to the file, compresses it or has compressed it.
+func gzipFile(filename string) error {
+	gzFilename := filename + ".gz"
+	unlock, err := lockFile(gzFilename + `_lock`)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	in, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return errLocked
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// the writers hold a shared lock, the last one to rotate away compresses it
+	if !tryLockExclusive(in) || fileExists(gzFilename) {
+		return errLocked
+	}
+
+	tmpFilename := gzFilename + `_tmp`
+	out, err := os.OpenFile(tmpFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpFilename, gzFilename)
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+
+	return os.Remove(filename)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// rotatedFile is a file which was created by a RotateWriter.
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// rotatedFiles returns the files which match the glob pattern,
+// including their generations, i.e "foo.log.1", from the newest to the oldest.
+// The "current" file, the lock files, the symbolic links and the temporary files are skipped.
+func (rl *RotateWriter) rotatedFiles(current string) ([]rotatedFile, error) {
+	var files []rotatedFile
+	seen := make(map[string]bool)
+	for _, pattern := range []string{rl.globPattern, rl.globPattern + ".*"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range matches {
+			// Ignore lock files
+			if seen[path] || path == current || strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") || strings.HasSuffix(path, "_tmp") {
+				continue
+			}
+			seen[path] = true
+
+			fi, err := os.Lstat(path)
+			if err != nil || fi.Mode()&os.ModeSymlink == os.ModeSymlink || fi.IsDir() {
+				continue
+			}
+			files = append(files, rotatedFile{path: path, modTime: fi.ModTime()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files, nil
+}
+
+// Rotate forcefully rotates the log files. If the generated file name
+// clash because file already exists, a numeric suffix of the form
+// ".1", ".2", ".3" and so forth are appended to the end of the log file.
+func (rl *RotateWriter) Rotate() error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if _, err := rl.getWriter_nolock(true, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close closes the current file and waits for the
+// background compressions, the next write opens a file again.
+func (rl *RotateWriter) Close() error {
+	rl.mutex.Lock()
+	var err error
+	if rl.outFh != nil {
+		err = rl.outFh.Close()
+		rl.outFh = nil
+	}
+	rl.mutex.Unlock()
+
+	// the handler of the compressions may write or rotate, wait without the lock
+	rl.compressing.Wait()
+	return err
+}
+
+// Sync commits the current contents of the file to the disk.
+func (rl *RotateWriter) Sync() error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.outFh == nil {
+		return nil
+	}
+	return rl.outFh.Sync()
+}
+
+// Reopen closes and reopens the current file, it's useful after an external
+// tool, i.e logrotate, has moved it, the next writes create it again.
+func (rl *RotateWriter) Reopen() error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.outFh == nil {
+		return nil
+	}
+
+	fh, err := openLogFile(rl.curFn)
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to reopen file %s: %s", rl.curFn, err))
+	}
+
+	rl.outFh.Close()
+	rl.outFh = fh
+	return nil
+}
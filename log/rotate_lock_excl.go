@@ -0,0 +1,55 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package log
+
+import (
+	"os"
+	"time"
+)
+
+// staleLockAge is the age of a lock file which is considered as left behind
+// by a crashed process, the lock is held only while the old files are purged.
+const staleLockAge = time.Minute
+
+// lockFile creates the "name" file exclusively, a file older than
+// the staleLockAge is removed and created again.
+// It returns errLocked when another process holds the lock.
+func lockFile(name string) (unlock func(), err error) {
+	fh, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		if fi, serr := os.Stat(name); serr == nil && time.Since(fi.ModTime()) > staleLockAge {
+			os.Remove(name)
+			fh, err = os.OpenFile(name, os.O_CREATE|os.O_EXCL, 0644)
+		}
+	}
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+
+	return func() {
+		fh.Close()
+		os.Remove(name)
+	}, nil
+}
+
+// tryLockShared always succeeds, the files of the writers can't be locked
+// on this platform so another process may compress a file which is written.
+func tryLockShared(fh *os.File) bool {
+	return true
+}
+
+// tryLockExclusive always succeeds, see `tryLockShared`.
+func tryLockExclusive(fh *os.File) bool {
+	return true
+}
+
+// removeLockedFile closes the "fh" of the "name" file and removes it,
+// an open file can't be removed on every platform.
+func removeLockedFile(fh *os.File, name string) {
+	fh.Close()
+	os.Remove(name)
+}
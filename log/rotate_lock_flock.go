@@ -0,0 +1,69 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package log
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an advisory flock on the "name" file, it's released by the
+// kernel if the process dies, so a file which was left behind is just locked again.
+// It returns errLocked when another process holds the lock.
+func lockFile(name string) (unlock func(), err error) {
+	fh, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.Flock(int(fh.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		fh.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+
+	// the holder removes the file before it unlocks it,
+	// if it's gone then we locked a file that nobody else can see.
+	fi, err := fh.Stat()
+	if err == nil {
+		var current os.FileInfo
+		if current, err = os.Stat(name); err == nil && !os.SameFile(fi, current) {
+			err = errLocked
+		}
+	}
+	if err != nil {
+		unix.Flock(int(fh.Fd()), unix.LOCK_UN)
+		fh.Close()
+		return nil, errLocked
+	}
+
+	return func() {
+		os.Remove(name)
+		unix.Flock(int(fh.Fd()), unix.LOCK_UN)
+		fh.Close()
+	}, nil
+}
+
+// tryLockShared takes a shared flock on the "fh" file, the writers hold it
+// until they rotate to another file so that it's not compressed meanwhile.
+// It reports whether the lock was taken, the lock is released when the file is closed.
+func tryLockShared(fh *os.File) bool {
+	return unix.Flock(int(fh.Fd()), unix.LOCK_SH|unix.LOCK_NB) == nil
+}
+
+// tryLockExclusive takes an exclusive flock on the "fh" file, it fails while
+// a writer holds a shared one. It reports whether the lock was taken.
+func tryLockExclusive(fh *os.File) bool {
+	return unix.Flock(int(fh.Fd()), unix.LOCK_EX|unix.LOCK_NB) == nil
+}
+
+// removeLockedFile removes the "name" file which is locked through "fh" and
+// then unlocks it, a writer which opens it meanwhile sees that it was removed.
+func removeLockedFile(fh *os.File, name string) {
+	os.Remove(name)
+	fh.Close()
+}
@@ -0,0 +1,153 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package log_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+// The environment variables of the writer processes started by TestRotateWriter_Processes.
+const (
+	rotateProcessDir   = "ROTATE_PROCESS_DIR"
+	rotateProcessID    = "ROTATE_PROCESS_ID"
+	rotateProcessCount = "ROTATE_PROCESS_COUNT"
+)
+
+const (
+	rotateProcesses = 4
+	rotateRecords   = 2000
+)
+
+// rotateProcess writes the records of a writer process, it runs in the
+// processes which are started by TestRotateWriter_Processes.
+// The processes close their writer once they have all written their records.
+func rotateProcess(dir, id string, count uint) {
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"),
+		WithRotationSize(1024), WithRotationCount(count), WithMaxAge(time.Hour), WithCompress(true))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for i := 0; i < rotateRecords; i++ {
+		fmt.Fprintf(w, "%s %04d\n", id, i)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "done-"+id), nil, 0644)
+	for i := 0; i < 1000; i++ {
+		if done, _ := filepath.Glob(filepath.Join(dir, "done-*")); len(done) == rotateProcesses {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Close()
+}
+
+// runRotateProcesses runs the writer processes in the "dir" with the rotation "count".
+func runRotateProcesses(dir string, count uint) {
+	var cmds []*exec.Cmd
+	for i := 0; i < rotateProcesses; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRotateWriter_Processes$")
+		cmd.Env = append(os.Environ(),
+			rotateProcessDir+"="+dir,
+			rotateProcessID+"="+strconv.Itoa(i),
+			rotateProcessCount+"="+strconv.Itoa(int(count)),
+		)
+		cmd.Stderr = os.Stderr
+		So(cmd.Start(), ShouldBeNil)
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		So(cmd.Wait(), ShouldBeNil)
+	}
+}
+
+// countRecords counts the records of the log files of the "dir", compressed or not.
+func countRecords(dir string) map[string]int {
+	matches, err := filepath.Glob(filepath.Join(dir, "app.log*"))
+	So(err, ShouldBeNil)
+
+	counts := make(map[string]int)
+	for _, path := range matches {
+		if strings.HasSuffix(path, "_lock") {
+			continue
+		}
+		So(path, ShouldNotEndWith, "_tmp")
+
+		f, err := os.Open(path)
+		So(err, ShouldBeNil)
+		var r io.Reader = f
+		if strings.HasSuffix(path, ".gz") {
+			zr, err := gzip.NewReader(f)
+			So(err, ShouldBeNil)
+			r = zr
+		}
+
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			counts[s.Text()]++
+		}
+		So(s.Err(), ShouldBeNil)
+		f.Close()
+	}
+	return counts
+}
+
+func TestRotateWriter_Processes(t *testing.T) {
+	if dir := os.Getenv(rotateProcessDir); dir != "" {
+		count, _ := strconv.Atoi(os.Getenv(rotateProcessCount))
+		rotateProcess(dir, os.Getenv(rotateProcessID), uint(count))
+		return
+	}
+
+	Convey("多个进程按大小轮转并压缩时记录既不丢失也不重复", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		runRotateProcesses(dir, 0)
+
+		compressed, err := filepath.Glob(filepath.Join(dir, "app.log*.gz"))
+		So(err, ShouldBeNil)
+		So(compressed, ShouldNotBeEmpty)
+
+		counts := countRecords(dir)
+		So(counts, ShouldHaveLength, rotateProcesses*rotateRecords)
+		for record, count := range counts {
+			if count != 1 {
+				So(record+" x"+strconv.Itoa(count), ShouldBeEmpty)
+			}
+		}
+	})
+	Convey("多个进程按数量清理时不会删除其他进程正在写入的文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		runRotateProcesses(dir, 1)
+
+		// the current file of each process is kept until it's closed,
+		// therefore the last records are never purged
+		counts := countRecords(dir)
+		for i := 0; i < rotateProcesses; i++ {
+			So(counts, ShouldContainKey, fmt.Sprintf("%d %04d", i, rotateRecords-1))
+		}
+		for record, count := range counts {
+			if count != 1 {
+				So(record+" x"+strconv.Itoa(count), ShouldBeEmpty)
+			}
+		}
+	})
+}
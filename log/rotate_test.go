@@ -0,0 +1,443 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+// tempLogDir returns a new temporary directory and a function which removes it.
+func tempLogDir() (string, func()) {
+	dir, err := ioutil.TempDir("", "rotate")
+	So(err, ShouldBeNil)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// readFile returns the content of the "name" file of the "dir".
+func readFile(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func TestRotateWriter_RotationSize(t *testing.T) {
+	Convey("超过 RotationSize 时切换到下一代文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotationSize(10), WithMaxAge(time.Hour))
+		So(err, ShouldBeNil)
+
+		for _, line := range []string{"0123456789\n", "abcdefghij\n", "ABCDEFGHIJ\n"} {
+			_, err = w.Write([]byte(line))
+			So(err, ShouldBeNil)
+		}
+
+		So(readFile(dir, "app.log"), ShouldEqual, "0123456789\n")
+		So(readFile(dir, "app.log.1"), ShouldEqual, "abcdefghij\n")
+		So(readFile(dir, "app.log.2"), ShouldEqual, "ABCDEFGHIJ\n")
+
+		Convey("未超过大小时继续写入当前文件", func() {
+			w, err := NewRotateWriter(filepath.Join(dir, "small.log"), WithRotationSize(100), WithMaxAge(time.Hour))
+			So(err, ShouldBeNil)
+
+			w.Write([]byte("one\n"))
+			w.Write([]byte("two\n"))
+			So(readFile(dir, "small.log"), ShouldEqual, "one\ntwo\n")
+		})
+	})
+}
+
+// waitForFiles waits until the files which match the "pattern" are the "want" ones.
+func waitForFiles(pattern string, want int) []string {
+	var matches []string
+	for i := 0; i < 100; i++ {
+		matches, _ = filepath.Glob(pattern)
+		if len(matches) == want {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return matches
+}
+
+func TestRotateWriter_RotationCount(t *testing.T) {
+	Convey("RotationCount 只保留最新的 N 个轮转文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		now := time.Now()
+		for i, name := range []string{"app-20200101.log", "app-20200102.log.1", "app-20200103.log", "app-20200104.log.gz"} {
+			path := filepath.Join(dir, name)
+			So(ioutil.WriteFile(path, []byte(name), 0644), ShouldBeNil)
+			modTime := now.Add(time.Duration(i-10) * time.Hour)
+			So(os.Chtimes(path, modTime, modTime), ShouldBeNil)
+		}
+
+		w, err := NewRotateWriter(filepath.Join(dir, "app-%Y%m%d.log"), WithRotationCount(2))
+		So(err, ShouldBeNil)
+		_, err = w.Write([]byte("hello\n"))
+		So(err, ShouldBeNil)
+
+		matches := waitForFiles(filepath.Join(dir, "app-*"), 3)
+		So(matches, ShouldHaveLength, 3)
+		So(matches, ShouldContain, filepath.Join(dir, "app-20200103.log"))
+		So(matches, ShouldContain, filepath.Join(dir, "app-20200104.log.gz"))
+		So(matches, ShouldContain, filepath.Join(dir, "app-"+now.Format("20060102")+".log"))
+	})
+
+	Convey("文件名互为前缀的 RotateWriter 不清理对方的文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		errFiles := []string{filepath.Join(dir, "app.error20200101.log"), filepath.Join(dir, "app.error20200102.log")}
+		for _, path := range errFiles {
+			So(ioutil.WriteFile(path, []byte("error\n"), 0644), ShouldBeNil)
+		}
+
+		w, err := NewRotateWriter(filepath.Join(dir, "app%Y%m%d.log"), WithRotationCount(1), WithMaxAge(time.Hour))
+		So(err, ShouldBeNil)
+		defer w.Close()
+		w.Write([]byte("hello\n"))
+
+		time.Sleep(50 * time.Millisecond)
+		for _, path := range errFiles {
+			_, err := os.Stat(path)
+			So(err, ShouldBeNil)
+		}
+	})
+}
+
+// readGzipFile returns the uncompressed content of the "name" file of the "dir".
+func readGzipFile(dir, name string) string {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err.Error()
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err.Error()
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func TestRotateWriter_Compress(t *testing.T) {
+	Convey("轮转后在后台压缩上一个文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotationSize(10), WithMaxAge(time.Hour), WithCompress(true))
+		So(err, ShouldBeNil)
+
+		w.Write([]byte("0123456789\n"))
+		w.Write([]byte("abcdefghij\n"))
+
+		var matches []string
+		for i := 0; i < 100; i++ {
+			if matches, _ = filepath.Glob(filepath.Join(dir, "app.log*")); len(matches) == 2 && strings.HasSuffix(matches[1], ".gz") {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(matches, ShouldResemble, []string{filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log.gz")})
+		So(readGzipFile(dir, "app.log.gz"), ShouldEqual, "0123456789\n")
+		So(readFile(dir, "app.log.1"), ShouldEqual, "abcdefghij\n")
+	})
+
+	Convey("重启后继续写入未满的下一代文件, 不会重新创建已压缩的文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		open := func() *RotateWriter {
+			w, err := NewRotateWriter(filepath.Join(dir, "app.log"), WithRotationSize(20), WithMaxAge(time.Hour), WithCompress(true))
+			So(err, ShouldBeNil)
+			return w
+		}
+		w := open()
+		w.Write([]byte("0123456789\n"))
+		w.Write([]byte("abcdefghij\n"))
+		w.Write([]byte("ABCDEFGHIJ\n"))
+		So(w.Close(), ShouldBeNil)
+
+		w = open()
+		w.Write([]byte("klmnopqrst\n"))
+		So(w.Close(), ShouldBeNil)
+
+		matches, _ := filepath.Glob(filepath.Join(dir, "app.log*"))
+		So(matches, ShouldResemble, []string{filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log.gz")})
+		So(readGzipFile(dir, "app.log.gz"), ShouldEqual, "0123456789\nabcdefghij\n")
+		So(readFile(dir, "app.log.1"), ShouldEqual, "ABCDEFGHIJ\nklmnopqrst\n")
+	})
+
+	Convey("清理不会删除正在压缩的文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		var mu sync.Mutex
+		compressed := map[string]bool{}
+		var purged []string
+		w, err := NewRotateWriter(filepath.Join(dir, "app.log"),
+			WithRotationSize(10),
+			WithRotationCount(1),
+			WithCompress(true),
+			WithHandler(RotateHandlerFunc(func(e RotateEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch e := e.(type) {
+				case *FileCompressedEvent:
+					compressed[e.File()] = true
+				case *FilesPurgedEvent:
+					purged = append(purged, e.Files()...)
+				}
+			})),
+		)
+		So(err, ShouldBeNil)
+
+		const rotations = 50
+		for i := 0; i <= rotations; i++ {
+			w.Write([]byte("0123456789\n"))
+		}
+		So(w.Close(), ShouldBeNil)
+
+		mu.Lock()
+		defer mu.Unlock()
+		So(compressed, ShouldHaveLength, rotations)
+		for _, path := range purged {
+			So(path, ShouldEndWith, ".gz")
+		}
+	})
+
+	Convey("事件处理函数写入时 Close 不会死锁", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		var w *RotateWriter
+		w, err := NewRotateWriter(filepath.Join(dir, "app.log"),
+			WithMaxAge(time.Hour),
+			WithCompress(true),
+			WithHandler(RotateHandlerFunc(func(e RotateEvent) {
+				if e, ok := e.(*FileCompressedEvent); ok {
+					w.Write([]byte("compressed " + filepath.Base(e.File()) + "\n"))
+				}
+			})),
+		)
+		So(err, ShouldBeNil)
+
+		w.Write([]byte("0123456789\n"))
+		So(w.Rotate(), ShouldBeNil)
+
+		closed := make(chan error, 1)
+		go func() { closed <- w.Close() }()
+		select {
+		case err = <-closed:
+			So(err, ShouldBeNil)
+		case <-time.After(5 * time.Second):
+			So("deadlock", ShouldBeEmpty)
+		}
+		So(readFile(dir, "app.log.1"), ShouldEqual, "compressed app.log\n")
+		So(readGzipFile(dir, "app.log.gz"), ShouldEqual, "0123456789\n")
+		w.Close()
+	})
+}
+
+func TestRotateWriter_LinkNameAndHandler(t *testing.T) {
+	Convey("软链接指向当前文件, 轮转与清理时发送事件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		events := make(chan RotateEvent, 10)
+		w, err := NewRotateWriter(filepath.Join(dir, "current.log"),
+			WithRotationSize(10),
+			WithRotationCount(1),
+			WithLinkName(filepath.Join(dir, "app.log")),
+			WithHandler(RotateHandlerFunc(func(e RotateEvent) { events <- e })),
+		)
+		So(err, ShouldBeNil)
+
+		w.Write([]byte("0123456789\n"))
+		target, err := os.Readlink(filepath.Join(dir, "app.log"))
+		So(err, ShouldBeNil)
+		So(target, ShouldEqual, "current.log")
+
+		w.Write([]byte("abcdefghij\n"))
+		old := time.Now().Add(-time.Hour)
+		So(os.Chtimes(filepath.Join(dir, "current.log"), old, old), ShouldBeNil)
+		w.Write([]byte("ABCDEFGHIJ\n"))
+
+		target, err = os.Readlink(filepath.Join(dir, "app.log"))
+		So(err, ShouldBeNil)
+		So(target, ShouldEqual, "current.log.2")
+		So(readFile(dir, "app.log"), ShouldEqual, "ABCDEFGHIJ\n")
+
+		rotated := map[string]string{}
+		var purged []string
+		for len(rotated) < 3 || purged == nil {
+			select {
+			case e := <-events:
+				switch e := e.(type) {
+				case *FileRotatedEvent:
+					rotated[e.CurrentFile()] = e.PreviousFile()
+				case *FilesPurgedEvent:
+					purged = e.Files()
+				}
+			case <-time.After(time.Second):
+				So("timeout", ShouldBeEmpty)
+			}
+		}
+
+		current := filepath.Join(dir, "current.log")
+		So(rotated, ShouldResemble, map[string]string{
+			current:        "",
+			current + ".1": current,
+			current + ".2": current + ".1",
+		})
+		So(purged, ShouldResemble, []string{current})
+	})
+}
+
+// fakeClock is a Clock which returns a fixed time.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
+
+func TestRotateWriter_Clock(t *testing.T) {
+	Convey("注入时钟后按时间边界确定地切分文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		jst := time.FixedZone("JST", 9*60*60)
+		clock := &fakeClock{now: time.Date(2018, 6, 1, 10, 59, 59, 0, jst)}
+		w, err := NewRotateWriter(filepath.Join(dir, "app.%Y%m%d%H%z.log"),
+			WithClock(clock),
+			WithRotationTime(time.Hour),
+			WithMaxAge(24*time.Hour),
+		)
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		w.Write([]byte("before\n"))
+		clock.Set(time.Date(2018, 6, 1, 11, 0, 0, 0, jst))
+		w.Write([]byte("after\n"))
+
+		So(readFile(dir, "app.2018060110+0900.log"), ShouldEqual, "before\n")
+		So(readFile(dir, "app.2018060111+0900.log"), ShouldEqual, "after\n")
+
+		Convey("Rotate 强制切换到下一代文件", func() {
+			So(w.Rotate(), ShouldBeNil)
+			w.Write([]byte("rotated\n"))
+			So(readFile(dir, "app.2018060111+0900.log.1"), ShouldEqual, "rotated\n")
+		})
+
+		Convey("Close 之后的写入重新打开当前文件", func() {
+			So(w.Close(), ShouldBeNil)
+			w.Write([]byte("reopened\n"))
+			So(readFile(dir, "app.2018060111+0900.log"), ShouldEqual, "after\nreopened\n")
+		})
+
+		Convey("ForceNewFile 在首次写入时创建新文件", func() {
+			w2, err := NewRotateWriter(filepath.Join(dir, "app.%Y%m%d%H%z.log"), WithClock(clock), WithRotationTime(time.Hour), ForceNewFile())
+			So(err, ShouldBeNil)
+			defer w2.Close()
+
+			w2.Write([]byte("new\n"))
+			So(readFile(dir, "app.2018060111+0900.log.1"), ShouldEqual, "new\n")
+		})
+	})
+}
+
+func TestRotateWriter_Reopen(t *testing.T) {
+	Convey("Reopen 在文件被外部工具移走后重新创建它", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		path := filepath.Join(dir, "app.log")
+		w, err := NewRotateWriter(path, WithMaxAge(time.Hour))
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		So(w.Reopen(), ShouldBeNil)
+		w.Write([]byte("before\n"))
+		So(os.Rename(path, path+".1"), ShouldBeNil)
+
+		So(w.Reopen(), ShouldBeNil)
+		w.Write([]byte("after\n"))
+		So(readFile(dir, "app.log.1"), ShouldEqual, "before\n")
+		So(readFile(dir, "app.log"), ShouldEqual, "after\n")
+	})
+}
+
+func TestRotateWriter_MultiProcess(t *testing.T) {
+	Convey("多个写入者按大小轮转时写入同一个下一代文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		path := filepath.Join(dir, "app.log")
+		w1, err := NewRotateWriter(path, WithRotationSize(30), WithMaxAge(time.Hour))
+		So(err, ShouldBeNil)
+		defer w1.Close()
+		w2, err := NewRotateWriter(path, WithRotationSize(30), WithMaxAge(time.Hour))
+		So(err, ShouldBeNil)
+		defer w2.Close()
+
+		w1.Write([]byte("1111111111\n"))
+		w2.Write([]byte("2222222222\n"))
+		w1.Write([]byte("3333333333\n"))
+		w2.Write([]byte("4444444444\n"))
+		w1.Write([]byte("5555555555\n"))
+
+		So(readFile(dir, "app.log"), ShouldEqual, "1111111111\n2222222222\n3333333333\n")
+		So(readFile(dir, "app.log.1"), ShouldEqual, "4444444444\n5555555555\n")
+		So(readFile(dir, "app.log.2"), ShouldContainSubstring, "no such file")
+	})
+
+	Convey("崩溃遗留的锁文件不会阻止清理", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		old := filepath.Join(dir, "app-20200101.log")
+		So(ioutil.WriteFile(old, []byte("old\n"), 0644), ShouldBeNil)
+		modTime := time.Now().Add(-48 * time.Hour)
+		So(os.Chtimes(old, modTime, modTime), ShouldBeNil)
+
+		current := filepath.Join(dir, "app-"+time.Now().Format("20060102")+".log")
+		So(ioutil.WriteFile(current+"_lock", nil, 0644), ShouldBeNil)
+		So(os.Chtimes(current+"_lock", modTime, modTime), ShouldBeNil)
+
+		w, err := NewRotateWriter(filepath.Join(dir, "app-%Y%m%d.log"), WithMaxAge(24*time.Hour))
+		So(err, ShouldBeNil)
+		defer w.Close()
+		w.Write([]byte("hello\n"))
+
+		So(waitForFiles(old, 0), ShouldBeEmpty)
+		So(waitForFiles(current+"_lock", 0), ShouldBeEmpty)
+	})
+}
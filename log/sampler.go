@@ -0,0 +1,153 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SamplingOptions are the options of `Logger#SetSampling`.
+//
+// Inside each Interval the First logs with the same level and message
+// are printed, then every Thereafter-th one, the rest are suppressed and counted.
+// When the Interval ends a "suppressed N similar messages" log is printed.
+type SamplingOptions struct {
+	// Interval is the sampling window, defaults to one second.
+	Interval time.Duration
+	// First is the number of logs which are always printed in each Interval.
+	First int
+	// Thereafter prints every Thereafter-th log after the First ones,
+	// if zero then all of them are suppressed.
+	Thereafter int
+}
+
+// DefaultSamplingInterval is the Interval of the `SamplingOptions` when it's not positive.
+const DefaultSamplingInterval = time.Second
+
+// samplerSweepSize is the number of the tracked messages
+// which triggers the removal of the expired ones.
+const samplerSweepSize = 4096
+
+type sampleKey struct {
+	level Level
+	msg   string
+}
+
+type sampleCounter struct {
+	start      time.Time
+	count      int
+	suppressed int
+	logger     *Logger
+	timer      *time.Timer
+}
+
+// sampler rate limits the repeated logs, it's shared between
+// a Logger and the loggers derived from it.
+type sampler struct {
+	mu       sync.Mutex
+	opts     map[Level]SamplingOptions
+	counters map[sampleKey]*sampleCounter
+}
+
+func newSampler() *sampler {
+	return &sampler{
+		opts:     make(map[Level]SamplingOptions),
+		counters: make(map[sampleKey]*sampleCounter),
+	}
+}
+
+func (s *sampler) set(level Level, opts SamplingOptions) {
+	s.mu.Lock()
+	if opts == (SamplingOptions{}) {
+		delete(s.opts, level)
+	} else {
+		if opts.Interval <= 0 {
+			opts.Interval = DefaultSamplingInterval
+		}
+		s.opts[level] = opts
+	}
+	s.mu.Unlock()
+}
+
+// sample reports whether the log of "l" with the "level" and "msg" should be printed.
+func (s *sampler) sample(l *Logger, level Level, msg string) bool {
+	s.mu.Lock()
+	opts, ok := s.opts[level]
+	if !ok {
+		s.mu.Unlock()
+		return true
+	}
+
+	now := time.Now()
+	key := sampleKey{level: level, msg: msg}
+	c := s.counters[key]
+	if c == nil || now.Sub(c.start) >= opts.Interval {
+		if c != nil && c.timer != nil && c.timer.Stop() {
+			// the window ended but its summary is still pending.
+			defer s.summarize(key, c)
+		}
+		if c == nil && len(s.counters) >= samplerSweepSize {
+			s.sweep(now)
+		}
+		c = &sampleCounter{start: now}
+		s.counters[key] = c
+	}
+
+	c.count++
+	if c.count <= opts.First ||
+		(opts.Thereafter > 0 && (c.count-opts.First)%opts.Thereafter == 0) {
+		s.mu.Unlock()
+		return true
+	}
+
+	c.suppressed++
+	c.logger = l
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.start.Add(opts.Interval).Sub(now), func() {
+			s.summarize(key, c)
+		})
+	}
+	s.mu.Unlock()
+	return false
+}
+
+// summarize prints the number of the logs that
+// the counter "c" suppressed, if any.
+func (s *sampler) summarize(key sampleKey, c *sampleCounter) {
+	s.mu.Lock()
+	if s.counters[key] == c {
+		delete(s.counters, key)
+	}
+	n, l := c.suppressed, c.logger
+	c.suppressed = 0
+	s.mu.Unlock()
+
+	if n > 0 {
+		l.emit(key.level, fmt.Sprintf("suppressed %d similar messages: %s", n, key.msg), true, false, nil)
+	}
+}
+
+// sweep removes the counters that have nothing to summarize
+// and their window has ended, it should be called under lock.
+func (s *sampler) sweep(now time.Time) {
+	for key, c := range s.counters {
+		if c.timer != nil {
+			continue
+		}
+		if opts, ok := s.opts[key.level]; !ok || now.Sub(c.start) >= opts.Interval {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// SetSampling rate limits the repeated logs of the "level",
+// see `SamplingOptions`. The logs are grouped by their level and message,
+// the sampling is shared with the loggers that share the outputs of "l".
+//
+// A zero "opts" removes the sampling of the "level".
+//
+// Returns itself.
+func (l *Logger) SetSampling(level Level, opts SamplingOptions) *Logger {
+	l.dispatcher.sampler.set(level, opts)
+	return l
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+)
+
+// nextVerbosity returns the level which follows the "level"
+// when the verbosity is cycled: error, warn, info, debug, trace and error again.
+func nextVerbosity(level Level) Level {
+	if level < ErrorLevel || level >= TraceLevel {
+		return ErrorLevel
+	}
+	return level + 1
+}
+
+// watchSignals runs the "actions" of the received signals until the returned stop is called.
+func watchSignals(actions map[os.Signal]func()) (stop func()) {
+	// signal.Notify relays all the signals when none is given.
+	if len(actions) == 0 {
+		return func() {}
+	}
+
+	sigs := make([]os.Signal, 0, len(actions))
+	for sig := range actions {
+		sigs = append(sigs, sig)
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				actions[sig]()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// cycleLevel sets the next level of `nextVerbosity` and prints it.
+func (l *Logger) cycleLevel() {
+	level := nextVerbosity(l.GetLevel())
+	l.setLevel(level)
+
+	if meta, ok := l.LevelMeta(level); ok {
+		l.Printf("log level changed to %s", meta.Name)
+	}
+}
+
+// reopenOnSignal reopens the outputs and reports the failure.
+func (l *Logger) reopenOnSignal() {
+	if err := l.Reopen(); err != nil {
+		l.Error(err)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"os"
+	"syscall"
+)
+
+// WatchSignals makes "l" react to the signals until the returned stop is called:
+// SIGUSR1 cycles the level of "l" through error, warn, info, debug and trace,
+// the children which haven't set their own level follow it,
+// and SIGHUP reopens the outputs, see `Reopen`, for the external logrotate.
+func (l *Logger) WatchSignals() (stop func()) {
+	return watchSignals(map[os.Signal]func(){
+		syscall.SIGUSR1: l.cycleLevel,
+		syscall.SIGHUP:  l.reopenOnSignal,
+	})
+}
@@ -0,0 +1,69 @@
+//go:build !windows
+// +build !windows
+
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+// waitForLevel waits until the level of "l" is the "want" one and returns it.
+func waitForLevel(l *Logger, want Level) Level {
+	for i := 0; i < 100 && l.GetLevel() != want; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return l.GetLevel()
+}
+
+func TestLogger_WatchSignals(t *testing.T) {
+	Convey("SIGUSR1 依次切换 error、warn、info、debug 和 trace 等级", t, func() {
+		w := &gateWriter{gate: make(chan struct{})}
+		close(w.gate)
+		l := New().SetTimeFormat("").SetOutput(w)
+		api := l.With("component", "api")
+
+		stop := l.WatchSignals()
+		defer stop()
+
+		for _, want := range []Level{DebugLevel, TraceLevel, ErrorLevel, WarnLevel, InfoLevel} {
+			So(syscall.Kill(os.Getpid(), syscall.SIGUSR1), ShouldBeNil)
+			So(waitForLevel(l, want), ShouldEqual, want)
+			So(api.GetLevel(), ShouldEqual, want)
+		}
+		So(w.String(), ShouldEqual, "log level changed to debug\n"+
+			"log level changed to trace\n"+
+			"log level changed to error\n"+
+			"log level changed to warn\n"+
+			"log level changed to info\n")
+	})
+
+	Convey("SIGHUP 重新打开被移走的文件", t, func() {
+		dir, remove := tempLogDir()
+		defer remove()
+
+		path := filepath.Join(dir, "app.log")
+		rw, err := NewRotateWriter(path, WithMaxAge(time.Hour))
+		So(err, ShouldBeNil)
+		defer rw.Close()
+
+		l := New().SetTimeFormat("").SetOutput(rw)
+		stop := l.WatchSignals()
+		defer stop()
+
+		l.Info("before")
+		So(os.Rename(path, path+".old"), ShouldBeNil)
+		So(syscall.Kill(os.Getpid(), syscall.SIGHUP), ShouldBeNil)
+
+		So(waitForFiles(path, 1), ShouldResemble, []string{path})
+		l.Info("after")
+		So(readFile(dir, "app.log.old"), ShouldEqual, "[INFO] before\n")
+		So(readFile(dir, "app.log"), ShouldEqual, "[INFO] after\n")
+	})
+}
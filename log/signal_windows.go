@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package log
+
+import (
+	"os"
+)
+
+// WatchSignals makes "l" react to the signals until the returned stop is called,
+// windows has no SIGUSR1 and SIGHUP, therefore it watches nothing.
+func (l *Logger) WatchSignals() (stop func()) {
+	return watchSignals(map[os.Signal]func(){})
+}
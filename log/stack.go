@@ -0,0 +1,85 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tm-ad/g-base/exceptions"
+)
+
+// EnableStacktrace makes "l" attach a stack trace to the logs
+// of the "level" and to the more important ones, i.e `ErrorLevel` attaches
+// it to the error and fatal logs. A stack trace is attached to the logs of
+// any level when an `exceptions.Exception` is passed as an argument.
+//
+// The runtime and the logger frames are removed from the trace.
+// `DisableLevel` disables the stack traces, which is the default.
+//
+// Returns itself.
+func (l *Logger) EnableStacktrace(level Level) *Logger {
+	l.mu.Lock()
+	l.stackLevel = level
+	l.mu.Unlock()
+
+	return l
+}
+
+// wantsStack reports whether a log of the "level" should have a stack trace.
+func (l *Logger) wantsStack(level Level, hasException bool) bool {
+	if l.stackLevel == DisableLevel {
+		return false
+	}
+	return hasException || (level != DisableLevel && level <= l.stackLevel)
+}
+
+// hasException reports whether one of the "args" is an `exceptions.Exception`.
+func hasException(args []interface{}) bool {
+	for _, arg := range args {
+		if _, ok := arg.(exceptions.Exception); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stackTrace returns the current call stack without the frames of
+// the runtime and of this package, consecutive duplicated frames are removed.
+func stackTrace() []exceptions.Frame {
+	frames := exceptions.CallFrames(1)
+
+	stack := frames[:0]
+	for _, f := range frames {
+		if isPackageFrame(f.Function) || strings.HasPrefix(f.Function, "runtime.") {
+			continue
+		}
+		if n := len(stack); n > 0 && stack[n-1] == f {
+			continue
+		}
+		stack = append(stack, f)
+	}
+
+	return stack
+}
+
+// appendStack appends the "stack" as an indented block, one line for
+// the function and one line for its file, like the go panics.
+func appendStack(b []byte, stack []exceptions.Frame) []byte {
+	for _, f := range stack {
+		b = append(b, "\n\t"...)
+		b = append(b, f.Function...)
+		b = append(b, "\n\t\t"...)
+		b = append(b, f.File...)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(f.Line), 10)
+	}
+	return b
+}
+
+// joinStack returns the frames of the "stack" separated by ", ".
+func joinStack(stack []exceptions.Frame) string {
+	frames := make([]string, len(stack))
+	for i, f := range stack {
+		frames[i] = f.String()
+	}
+	return strings.Join(frames, ", ")
+}
@@ -0,0 +1,305 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogFormat is the message format of a `SyslogWriter`.
+type SyslogFormat uint8
+
+const (
+	// RFC5424 is the format of the modern syslog daemons, it's the default one.
+	RFC5424 SyslogFormat = iota
+	// RFC3164 is the legacy BSD format.
+	RFC3164
+)
+
+// SyslogFacility is the facility of the syslog messages.
+type SyslogFacility int
+
+// The syslog facilities which are usually used by the applications.
+const (
+	FacilityUser   SyslogFacility = 1
+	FacilityDaemon SyslogFacility = 3
+	FacilityLocal0 SyslogFacility = 16
+	FacilityLocal1 SyslogFacility = 17
+	FacilityLocal2 SyslogFacility = 18
+	FacilityLocal3 SyslogFacility = 19
+	FacilityLocal4 SyslogFacility = 20
+	FacilityLocal5 SyslogFacility = 21
+	FacilityLocal6 SyslogFacility = 22
+	FacilityLocal7 SyslogFacility = 23
+)
+
+// SyslogSeverity is the severity of the syslog messages.
+type SyslogSeverity int
+
+// The syslog severities.
+const (
+	SeverityEmergency SyslogSeverity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+// SyslogSeverities maps the levels to the syslog severities,
+// callers are allowed to modify it in order to map their own levels.
+// The levels which are not mapped are sent as `SeverityDebug`.
+var SyslogSeverities = map[Level]SyslogSeverity{
+	DisableLevel: SeverityInfo,
+	FatalLevel:   SeverityCritical,
+	PanicLevel:   SeverityCritical,
+	ErrorLevel:   SeverityError,
+	WarnLevel:    SeverityWarning,
+	InfoLevel:    SeverityInfo,
+	DebugLevel:   SeverityDebug,
+	TraceLevel:   SeverityDebug,
+}
+
+func syslogSeverity(level Level) SyslogSeverity {
+	if s, ok := SyslogSeverities[level]; ok {
+		return s
+	}
+	return SeverityDebug
+}
+
+// syslogPaths are the local syslog sockets which are tried when the `SyslogOptions#Network` is empty.
+var syslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// DefaultSyslogDialTimeout is the dial timeout of a `SyslogWriter`
+// when the `SyslogOptions#DialTimeout` is not positive.
+const DefaultSyslogDialTimeout = 5 * time.Second
+
+// SyslogOptions are the options of `NewSyslogWriter`.
+type SyslogOptions struct {
+	// Network is "unixgram", "unix", "udp" or "tcp",
+	// when empty the local syslog socket is used, i.e "/dev/log".
+	Network string
+	// Addr is the socket path or the "host:port" of the syslog server.
+	Addr string
+	// Format is the message format, defaults to `RFC5424`.
+	Format SyslogFormat
+	// Facility is the facility of the messages, defaults to `FacilityUser`.
+	Facility SyslogFacility
+	// Tag is the app name of the messages, defaults to the name of the executable.
+	Tag string
+	// Hostname is the host name of the messages, defaults to the `os.Hostname`.
+	Hostname string
+	// Encoder encodes the message part, after the syslog header,
+	// defaults to the prefix, the caller, the message, the fields and the stack trace.
+	// The level and the time are part of the header.
+	Encoder Encoder
+	// DialTimeout is the timeout of the connection, defaults to `DefaultSyslogDialTimeout`.
+	DialTimeout time.Duration
+}
+
+// SyslogWriter is an output which sends each log as a syslog message,
+// its severity is mapped from the level of the log through the `SyslogSeverities`.
+//
+// The stream connections, "unix" and "tcp", frame the RFC5424 messages by their length
+// and the RFC3164 ones by a new line, as RFC6587 describes.
+// A broken connection is reopened on the next write.
+//
+// It should be added through `Logger#AddOutput` or `Logger#AddLevelOutput`, i.e
+//
+// w, err := log.NewSyslogWriter(log.SyslogOptions{Network: "udp", Addr: "10.0.0.1:514"})
+// l.AddOutput(w)
+type SyslogWriter struct {
+	opts SyslogOptions
+	pid  int
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+var _ encodedWriter = (*SyslogWriter)(nil)
+
+// NewSyslogWriter returns a new `SyslogWriter` which is connected to the syslog server of the "opts".
+func NewSyslogWriter(opts SyslogOptions) (*SyslogWriter, error) {
+	if opts.Facility == 0 {
+		opts.Facility = FacilityUser
+	}
+	if opts.Tag == "" {
+		opts.Tag = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.Encoder == nil {
+		opts.Encoder = EncoderFunc(encodeSyslogMessage)
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultSyslogDialTimeout
+	}
+
+	w := &SyslogWriter{opts: opts, pid: os.Getpid()}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect opens a new connection, the lock should be held by the caller.
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if w.opts.Network != "" {
+		conn, err := net.DialTimeout(w.opts.Network, w.opts.Addr, w.opts.DialTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		return nil
+	}
+
+	paths := syslogPaths
+	if w.opts.Addr != "" {
+		paths = []string{w.opts.Addr}
+	}
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range paths {
+			if conn, err := net.DialTimeout(network, path, w.opts.DialTimeout); err == nil {
+				w.conn = conn
+				w.opts.Network, w.opts.Addr = network, path
+				return nil
+			}
+		}
+	}
+	return errors.New("log: local syslog server not found")
+}
+
+func (w *SyslogWriter) stream() bool {
+	switch w.opts.Network {
+	case "unix", "tcp", "tcp4", "tcp6":
+		return true
+	default:
+		return false
+	}
+}
+
+// Write sends the "p" syslog message, which is encoded by the `Encoder`,
+// it reconnects and retries once when the connection is broken.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		if err := w.send(msg); err == nil {
+			return len(p), nil
+		}
+	}
+
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+	if err := w.send(msg); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *SyslogWriter) send(msg string) error {
+	var err error
+	switch {
+	case !w.stream():
+		_, err = w.conn.Write([]byte(msg))
+	case w.opts.Format == RFC5424:
+		_, err = w.conn.Write([]byte(strconv.Itoa(len(msg)) + " " + msg))
+	default:
+		_, err = w.conn.Write([]byte(msg + "\n"))
+	}
+	return err
+}
+
+// Close closes the connection, the next write reconnects.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// Reopen reconnects to the syslog server, see `Logger#Reopen`.
+func (w *SyslogWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.connect()
+}
+
+// Encoder returns the encoder of the syslog messages, the `Logger` uses it
+// instead of its own encoder for the `SyslogWriter` outputs.
+func (w *SyslogWriter) Encoder() Encoder {
+	return EncoderFunc(w.encode)
+}
+
+func (w *SyslogWriter) encode(l *Log, colored bool) ([]byte, error) {
+	msg, err := w.opts.Encoder.Encode(l, false)
+	if err != nil {
+		return nil, err
+	}
+
+	pri := int(w.opts.Facility)*8 + int(syslogSeverity(l.Level))
+	if w.opts.Format == RFC3164 {
+		hostname := w.opts.Hostname
+		if hostname == "" {
+			hostname = "localhost"
+		}
+		header := fmt.Sprintf("<%d>%s %s %s[%d]: ", pri, l.Time.Format(time.Stamp), hostname, w.opts.Tag, w.pid)
+		return append([]byte(header), msg...), nil
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ", pri, l.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderValue(w.opts.Hostname, 255), syslogHeaderValue(w.opts.Tag, 48), w.pid)
+	return append([]byte(header), msg...), nil
+}
+
+// syslogHeaderValue returns the "s" as an RFC5424 header value,
+// the "-" nil value when empty, without spaces and cut at "max" bytes.
+func syslogHeaderValue(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+	s = strings.Replace(s, " ", "_", -1)
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// encodeSyslogMessage is the default encoder of the syslog messages.
+func encodeSyslogMessage(l *Log, colored bool) ([]byte, error) {
+	b := make([]byte, 0, len(l.Logger.Prefix)+len(l.Message)+32)
+	b = append(b, l.Logger.Prefix...)
+	if c := l.Caller; c != nil {
+		b = append(b, c.String()...)
+		b = append(b, ' ')
+	}
+	b = append(b, l.Message...)
+	b = appendFields(b, l.Fields)
+	return appendStack(b, l.Stack), nil
+}
@@ -0,0 +1,120 @@
+package log_test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/tm-ad/g-base/log"
+)
+
+// readPacket reads a datagram of the "conn" or fails after a second.
+func readPacket(conn net.PacketConn) string {
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		return err.Error()
+	}
+	return string(buf[:n])
+}
+
+func newSyslogLogger(opts SyslogOptions) (*Logger, *SyslogWriter) {
+	w, err := NewSyslogWriter(opts)
+	So(err, ShouldBeNil)
+
+	l := New().SetOutput(NopOutput).AddOutput(w)
+	return l, w
+}
+
+func TestSyslogWriter(t *testing.T) {
+	Convey("SyslogWriter 通过 unix datagram 发送 RFC5424 消息", t, func() {
+		dir, err := ioutil.TempDir("", "syslog")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		addr := filepath.Join(dir, "log.sock")
+		server, err := net.ListenPacket("unixgram", addr)
+		So(err, ShouldBeNil)
+
+		l, w := newSyslogLogger(SyslogOptions{Network: "unixgram", Addr: addr, Facility: FacilityLocal0, Tag: "app", Hostname: "host"})
+		defer w.Close()
+
+		l.With("user", 42).Warn("disk is almost full")
+		msg := readPacket(server)
+		pattern := `^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ host app ` + strconv.Itoa(os.Getpid()) + ` - - disk is almost full user=42$`
+		So(msg, shouldMatch, pattern)
+
+		Convey("服务端重启后自动重连", func() {
+			server.Close()
+			os.Remove(addr)
+			server, err = net.ListenPacket("unixgram", addr)
+			So(err, ShouldBeNil)
+			defer server.Close()
+
+			l.Error("reconnected")
+			So(readPacket(server), ShouldEndWith, " - - reconnected")
+		})
+	})
+
+	Convey("SyslogWriter 通过 UDP 发送 RFC3164 消息", t, func() {
+		server, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer server.Close()
+
+		l, w := newSyslogLogger(SyslogOptions{Network: "udp", Addr: server.LocalAddr().String(), Format: RFC3164, Tag: "app", Hostname: "host"})
+		defer w.Close()
+
+		l.Info("hello")
+		pattern := `^<14>\w{3} [ \d]\d \d\d:\d\d:\d\d host app\[` + strconv.Itoa(os.Getpid()) + `\]: hello$`
+		So(readPacket(server), shouldMatch, pattern)
+	})
+
+	Convey("SyslogWriter 通过 TCP 按长度分帧发送消息", t, func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer ln.Close()
+
+		l, w := newSyslogLogger(SyslogOptions{Network: "tcp", Addr: ln.Addr().String(), Tag: "app", Hostname: "host"})
+		defer w.Close()
+
+		conn, err := ln.Accept()
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		l.Error("first")
+		l.Debug("ignored")
+		l.Error("second")
+
+		r := bufio.NewReader(conn)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for _, want := range []string{"first", "second"} {
+			size, err := r.ReadString(' ')
+			So(err, ShouldBeNil)
+			n, err := strconv.Atoi(size[:len(size)-1])
+			So(err, ShouldBeNil)
+
+			msg := make([]byte, n)
+			_, err = io.ReadFull(r, msg)
+			So(err, ShouldBeNil)
+			So(string(msg), ShouldStartWith, "<11>1 ")
+			So(string(msg), ShouldEndWith, " - - "+want)
+		}
+	})
+}
+
+// shouldMatch asserts that the "actual" string matches the "expected" regular expression.
+func shouldMatch(actual interface{}, expected ...interface{}) string {
+	if regexp.MustCompile(expected[0].(string)).MatchString(actual.(string)) {
+		return ""
+	}
+	return "Expected '" + actual.(string) + "' to match " + expected[0].(string)
+}